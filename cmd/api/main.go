@@ -2,40 +2,302 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go-redis/internal/bans"
 	"go-redis/internal/config"
+	"go-redis/internal/events"
+	"go-redis/internal/health"
+	"go-redis/internal/logging"
+	"go-redis/internal/metrics"
+	"go-redis/internal/middleware"
 	"go-redis/internal/routes"
+	"go-redis/internal/store"
+	"go-redis/internal/tracing"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
 var ctx = context.Background()
 
 func main() {
-	cfg := config.Load()
+	reloader, err := config.NewReloader(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	cfg := reloader.Config()
+	if err := logging.Setup(os.Stderr, cfg.Logging); err != nil {
+		log.Fatalf("Invalid logging configuration: %v", err)
+	}
+	log.Printf("Configuration loaded from %s", reloader)
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: "",
-		DB:       0,
-	})
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if cfg.Tracing.Enabled {
+		log.Printf("Tracing enabled, exporting to %s", cfg.Tracing.Exporter)
+	}
+
+	var (
+		lb        store.LeaderboardStore
+		kv        store.KV
+		publisher events.Publisher
+		banStore  bans.Store
+		// Closed on shutdown; nil when the backend does not use them.
+		limiter        middleware.Limiter
+		memoryKV       *store.MemoryKV
+		redisPublisher *events.RedisPublisher
+		redisClient    redis.UniversalClient
+	)
+	checker := health.NewChecker(cfg.Health.CacheTTL, cfg.Health.CheckTimeout)
+	switch cfg.Store.Backend {
+	case config.StoreMemory:
+		lb = store.NewMemoryStore(cfg.Store.Board)
+		memoryKV = store.NewMemoryKV(time.Minute)
+		kv = memoryKV
+		publisher = events.LogPublisher{}
+		checker.Add("store", health.Store(lb))
+		log.Printf("Using in-memory store; data will not survive a restart")
+	case config.StoreRedis:
+		redisClient, err = newRedisClient(&cfg.Redis)
+		if err != nil {
+			log.Fatalf("Invalid Redis configuration: %v", err)
+		}
+		redisClient.AddHook(metrics.RedisHook())
+		if cfg.Tracing.Enabled {
+			if err := redisotel.InstrumentTracing(redisClient); err != nil {
+				log.Fatalf("Failed to instrument Redis tracing: %v", err)
+			}
+		}
+		if _, err := redisClient.Ping(ctx).Result(); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		log.Printf("Connected to Redis (%s)", cfg.Redis.Mode)
+
+		redisStore := store.NewRedisStore(redisClient, cfg.Store.Board)
+		if rebuilt, err := redisStore.Reindex(ctx); err != nil {
+			log.Fatalf("Failed to index leaderboard: %v", err)
+		} else if rebuilt {
+			log.Printf("Indexed leaderboard %q for rank and stats queries", cfg.Store.Board)
+		}
+		lb = redisStore
+		kv = store.NewRedisKV(redisClient)
+		redisPublisher = events.NewRedisPublisher(redisClient, events.PublisherConfig{
+			Channel: cfg.Events.Channel,
+			Stream:  cfg.Events.Stream,
+			Buffer:  cfg.Events.QueueSize,
+		})
+		publisher = redisPublisher
+		banStore = bans.NewRedisStore(redisClient)
 
-	if _, err := redisClient.Ping(ctx).Result(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+		checker.Add("redis_ping", health.RedisPing(redisClient, cfg.Health.MaxPingLatency))
+		checker.Add("redis_pool", health.RedisPool(redisClient, cfg.Health.MaxPoolUsage))
+		checker.Add("redis_replication", health.RedisReplication(redisClient, cfg.Health.MaxReplicationLag))
+
+		if cfg.RateLimit.Backend == config.LimiterRedis {
+			rl := cfg.RateLimit
+			local := middleware.NewRateLimiter(rl.CleanupInterval)
+			limiter = middleware.NewRedisRateLimiter(redisClient, rl.RedisTimeout, local)
+			log.Printf("Rate limits shared through Redis")
+		}
 	}
-	log.Println("Connected to Redis")
 
-	router := routes.SetupRoutes(redisClient)
+	router := routes.SetupRoutes(cfg, routes.Deps{
+		Store:     lb,
+		KV:        kv,
+		Publisher: publisher,
+		Health:    checker,
+		Limiter:   limiter,
+		Bans:      banStore,
+	})
+	reloader.OnReload(router.Reload)
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	go reloader.Watch(watchCtx, configWatchInterval)
+	go reloadOnSIGHUP(reloader)
 
-	serverAddr := ":" + cfg.Port
-	log.Printf("Server starting on port %s\n", cfg.Port)
+	port := cfg.Server.Port
+	serverAddr := ":" + port
+	log.Printf("Server starting on port %s\n", port)
 	log.Printf("Available endpoints:")
-	log.Printf("  POST http://localhost:%s/score - Submit a score (requires JSON body: {\"player\": \"name\", \"score\": 100})", cfg.Port)
-	log.Printf("  GET  http://localhost:%s/health - Health check\n", cfg.Port)
+	log.Printf("  POST http://localhost:%s/score - Submit a score (requires JSON body: {\"player\": \"name\", \"score\": 100})", port)
+	log.Printf("  POST http://localhost:%s/v2/score - Submit a score (v2 envelope, problem+json errors)", port)
+	log.Printf("  GET  http://localhost:%s/health - Health check", port)
+	log.Printf("  GET  http://localhost:%s/readyz - Detailed readiness report\n", port)
 
-	if err := http.ListenAndServe(serverAddr, router); err != nil {
+	srv := &http.Server{
+		Addr:         serverAddr,
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Server failed to start: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+	}
+	signal.Stop(stop)
+	stopWatch()
+
+	// Settings read here may have been changed by a reload.
+	cfg = reloader.Config()
+	router.Drain()
+	if cfg.Server.DrainDelay > 0 {
+		log.Printf("Health check failing; waiting %s before closing the listener", cfg.Server.DrainDelay)
+		time.Sleep(cfg.Server.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining connections: %v", err)
+	}
+	if err := router.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error waiting for background work: %v", err)
+	}
+	if redisPublisher != nil {
+		if err := redisPublisher.Close(shutdownCtx); err != nil {
+			log.Printf("Error flushing events: %v", err)
+		}
+	}
+	if memoryKV != nil {
+		memoryKV.Stop()
+	}
+	if redisClient != nil {
+		if err := redisClient.Close(); err != nil {
+			log.Printf("Error closing Redis client: %v", err)
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+	log.Printf("Server stopped")
+}
+
+// reloadOnSIGHUP reloads the configuration each time the process receives
+// SIGHUP.
+func reloadOnSIGHUP(reloader *config.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Printf("[config] SIGHUP received, reloading")
+		reloader.Reload()
+	}
+}
+
+// newRedisClient builds the client for the configured deployment mode.
+func newRedisClient(cfg *config.RedisConfig) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(&cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Mode {
+	case config.RedisStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:         cfg.Addrs[0],
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			DB:           cfg.DB,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			PoolTimeout:  cfg.PoolTimeout,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+		}), nil
+	case config.RedisSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.Addrs,
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			DB:            cfg.DB,
+			TLSConfig:     tlsConfig,
+			PoolSize:      cfg.PoolSize,
+			MinIdleConns:  cfg.MinIdleConns,
+			PoolTimeout:   cfg.PoolTimeout,
+			DialTimeout:   cfg.DialTimeout,
+			ReadTimeout:   cfg.ReadTimeout,
+			WriteTimeout:  cfg.WriteTimeout,
+			MaxRetries:    cfg.MaxRetries,
+		}), nil
+	case config.RedisCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.Addrs,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			TLSConfig:    tlsConfig,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			PoolTimeout:  cfg.PoolTimeout,
+			DialTimeout:  cfg.DialTimeout,
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			MaxRetries:   cfg.MaxRetries,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown Redis mode %q", cfg.Mode)
+}
+
+// newTLSConfig returns nil when TLS is disabled.
+func newTLSConfig(cfg *config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
+	return tlsConfig, nil
 }
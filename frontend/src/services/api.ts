@@ -22,9 +22,9 @@ export const api = {
   },
 
   // Get players around a specific player
-  async getPlayersAround(player: string, limit: number = 5): Promise<LeaderboardResponse> {
+  async getPlayersAround(player: string, radius: number = 2): Promise<LeaderboardResponse> {
     const response = await axios.get(
-      `${API_BASE_URL}/leaderboard/around/${encodeURIComponent(player)}?limit=${limit}`
+      `${API_BASE_URL}/leaderboard/around/${encodeURIComponent(player)}?radius=${radius}`
     );
     return response.data;
   },
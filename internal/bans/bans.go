@@ -0,0 +1,45 @@
+// Package bans keeps temporary bans of clients that keep exceeding their
+// rate limits.
+package bans
+
+import (
+	"context"
+	"time"
+)
+
+// Ban is one client's ban.
+type Ban struct {
+	// Client is the banned address, or IPv6 network.
+	Client string `json:"client"`
+	// Violations is how many rate limit violations led to the ban.
+	Violations int       `json:"violations"`
+	Since      time.Time `json:"since"`
+	Expires    time.Time `json:"expires"`
+}
+
+// Policy decides when a client is banned: once it has Threshold
+// violations within Window, for Duration.
+type Policy struct {
+	Threshold int
+	Window    time.Duration
+	Duration  time.Duration
+}
+
+// Store records violations and the bans they lead to. MemoryStore keeps
+// them in process; RedisStore shares them between replicas.
+type Store interface {
+	// Strike records a violation by client and bans it once the policy's
+	// threshold is reached. It returns the ban when one was imposed.
+	Strike(ctx context.Context, client string, p Policy) (*Ban, error)
+	// Get returns client's ban, or nil when it is not banned.
+	Get(ctx context.Context, client string) (*Ban, error)
+	// List returns every ban in force.
+	List(ctx context.Context) ([]Ban, error)
+	// Lift removes client's ban, reporting whether there was one.
+	Lift(ctx context.Context, client string) (bool, error)
+}
+
+func newBan(client string, violations int, d time.Duration) *Ban {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Ban{Client: client, Violations: violations, Since: now, Expires: now.Add(d)}
+}
@@ -0,0 +1,169 @@
+package bans_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-redis/internal/bans"
+	"go-redis/internal/keys"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func() (bans.Store, string) { return bans.NewMemoryStore(), "" })
+}
+
+// TestRedisStore needs a Redis server at REDIS_ADDR. Each case gets
+// clients of its own, whose keys are deleted when the case ends.
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis unavailable: %v", err)
+	}
+	n := 0
+	testStore(t, func() (bans.Store, string) {
+		n++
+		prefix := "test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":" + strconv.Itoa(n) + ":"
+		t.Cleanup(func() {
+			for _, c := range []string{"a", "b"} {
+				rdb.Del(context.Background(), keys.Strikes(prefix+c), keys.Ban(prefix+c))
+			}
+		})
+		return bans.NewRedisStore(rdb), prefix
+	})
+}
+
+// testStore checks that a Store behaves as the interface documents. Cases
+// ban clients a and b; newStore must return a store holding no bans or
+// strikes for the clients named with the prefix it returns.
+func testStore(t *testing.T, newStore func() (bans.Store, string)) {
+	policy := bans.Policy{Threshold: 3, Window: time.Minute, Duration: time.Hour}
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, s bans.Store, a, b string)
+	}{
+		{"strikes below the threshold do not ban", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			strike(t, s, a, policy, 2)
+			checkBan(t, s, a, nil)
+		}},
+		{"the threshold bans", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			ban := strike(t, s, a, policy, 3)
+			if ban == nil {
+				t.Fatal("the third strike did not ban")
+			}
+			if ban.Client != a || ban.Violations != 3 || ban.Expires.Sub(ban.Since) != policy.Duration {
+				t.Errorf("ban = %+v", ban)
+			}
+			checkBan(t, s, a, ban)
+			checkList(t, s, a, b, a)
+		}},
+		{"clients are counted apart", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			strike(t, s, a, policy, 2)
+			strike(t, s, b, policy, 2)
+			checkBan(t, s, a, nil)
+			checkBan(t, s, b, nil)
+		}},
+		{"strikes outside the window are forgotten", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			p := policy
+			p.Window = 50 * time.Millisecond
+			strike(t, s, a, p, 2)
+			time.Sleep(2 * p.Window)
+			if ban := strike(t, s, a, p, 1); ban != nil {
+				t.Errorf("banned by strikes from an earlier window: %+v", ban)
+			}
+		}},
+		{"a ban starts a new count", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			strike(t, s, a, policy, 3)
+			if _, err := s.Lift(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+			if ban := strike(t, s, a, policy, 1); ban != nil {
+				t.Errorf("banned again by a single strike: %+v", ban)
+			}
+		}},
+		{"bans expire", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			p := policy
+			p.Threshold, p.Duration = 1, time.Second
+			if strike(t, s, a, p, 1) == nil {
+				t.Fatal("not banned")
+			}
+			time.Sleep(p.Duration + 100*time.Millisecond)
+			checkBan(t, s, a, nil)
+			checkList(t, s, a, b)
+			if lifted, err := s.Lift(ctx, a); err != nil || lifted {
+				t.Errorf("Lift of an expired ban = %t, %v", lifted, err)
+			}
+		}},
+		{"lift", func(t *testing.T, ctx context.Context, s bans.Store, a, b string) {
+			strike(t, s, a, policy, 3)
+			strike(t, s, b, policy, 3)
+			checkList(t, s, a, b, a, b)
+			if lifted, err := s.Lift(ctx, a); err != nil || !lifted {
+				t.Fatalf("Lift = %t, %v", lifted, err)
+			}
+			checkBan(t, s, a, nil)
+			checkList(t, s, a, b, b)
+			if lifted, err := s.Lift(ctx, a); err != nil || lifted {
+				t.Errorf("second Lift = %t, %v", lifted, err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, prefix := newStore()
+			tt.run(t, context.Background(), s, prefix+"a", prefix+"b")
+		})
+	}
+}
+
+// strike records n violations by client, returning the last one's ban.
+func strike(t *testing.T, s bans.Store, client string, p bans.Policy, n int) *bans.Ban {
+	t.Helper()
+	var ban *bans.Ban
+	for range n {
+		var err error
+		if ban, err = s.Strike(context.Background(), client, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ban
+}
+
+func checkBan(t *testing.T, s bans.Store, client string, want *bans.Ban) {
+	t.Helper()
+	got, err := s.Get(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (got == nil) != (want == nil) || got != nil && !got.Expires.Equal(want.Expires) {
+		t.Errorf("Get(%s) = %+v, want %+v", client, got, want)
+	}
+}
+
+// checkList compares the bans of a and b that List returns to want.
+func checkList(t *testing.T, s bans.Store, a, b string, want ...string) {
+	t.Helper()
+	list, err := s.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ban := range list {
+		if ban.Client == a || ban.Client == b {
+			got = append(got, ban.Client)
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
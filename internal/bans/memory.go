@@ -0,0 +1,106 @@
+package bans
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps bans in a map. Expired entries are dropped when they
+// are next looked at, and in a sweep at most once a minute.
+type MemoryStore struct {
+	mu        sync.Mutex
+	bans      map[string]Ban
+	strikes   map[string]strikes
+	lastSweep time.Time
+}
+
+type strikes struct {
+	count int
+	reset time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		bans:    make(map[string]Ban),
+		strikes: make(map[string]strikes),
+	}
+}
+
+func (s *MemoryStore) Strike(ctx context.Context, client string, p Policy) (*Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+
+	st := s.strikes[client]
+	if !now.Before(st.reset) {
+		st = strikes{reset: now.Add(p.Window)}
+	}
+	st.count++
+	if st.count < p.Threshold {
+		s.strikes[client] = st
+		return nil, nil
+	}
+	delete(s.strikes, client)
+	b := newBan(client, st.count, p.Duration)
+	s.bans[client] = *b
+	return b, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, client string) (*Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bans[client]
+	if !ok {
+		return nil, nil
+	}
+	if !time.Now().Before(b.Expires) {
+		delete(s.bans, client)
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Ban, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	list := make([]Ban, 0, len(s.bans))
+	for client, b := range s.bans {
+		if !now.Before(b.Expires) {
+			delete(s.bans, client)
+			continue
+		}
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Client < list[j].Client })
+	return list, nil
+}
+
+func (s *MemoryStore) Lift(ctx context.Context, client string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bans[client]
+	delete(s.bans, client)
+	return ok && time.Now().Before(b.Expires), nil
+}
+
+// sweep drops expired strikes and bans. Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for client, st := range s.strikes {
+		if !now.Before(st.reset) {
+			delete(s.strikes, client)
+		}
+	}
+	for client, b := range s.bans {
+		if !now.Before(b.Expires) {
+			delete(s.bans, client)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package bans
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go-redis/internal/keys"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// strikeScript counts a violation in a window starting at the first one
+// and, at the threshold, replaces the count with the ban.
+//
+// KEYS[1] strikes, KEYS[2] ban; ARGV[1] window ms, ARGV[2] threshold,
+// ARGV[3] ban duration ms, ARGV[4] the ban as JSON. Returns the violation count when it led to a
+// ban, 0 otherwise.
+var strikeScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+if n < tonumber(ARGV[2]) then
+  return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('SET', KEYS[2], ARGV[4], 'PX', ARGV[3])
+return n
+`)
+
+// RedisStore keeps each ban as a JSON string expiring with it, so every
+// replica honours it.
+type RedisStore struct {
+	rdb redis.UniversalClient
+}
+
+func NewRedisStore(rdb redis.UniversalClient) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) Strike(ctx context.Context, client string, p Policy) (*Ban, error) {
+	b := newBan(client, p.Threshold, p.Duration)
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	// The key expires with the ban, whose times are truncated to the second.
+	n, err := strikeScript.Run(ctx, s.rdb, []string{keys.Strikes(client), keys.Ban(client)},
+		p.Window.Milliseconds(), p.Threshold, time.Until(b.Expires).Milliseconds(), data).Int()
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, client string) (*Ban, error) {
+	data, err := s.rdb.Get(ctx, keys.Ban(client)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Ban
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// List scans for ban keys, on every master in cluster mode.
+func (s *RedisStore) List(ctx context.Context) ([]Ban, error) {
+	var (
+		mu   sync.Mutex
+		list []Ban
+	)
+	collect := func(ctx context.Context, c redis.UniversalClient) error {
+		iter := c.Scan(ctx, 0, keys.BanPattern, 100).Iterator()
+		for iter.Next(ctx) {
+			data, err := c.Get(ctx, iter.Val()).Bytes()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var b Ban
+			if err := json.Unmarshal(data, &b); err != nil {
+				return err
+			}
+			mu.Lock()
+			list = append(list, b)
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	var err error
+	if cc, ok := s.rdb.(*redis.ClusterClient); ok {
+		err = cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return collect(ctx, c)
+		})
+	} else {
+		err = collect(ctx, s.rdb)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Client < list[j].Client })
+	return list, nil
+}
+
+func (s *RedisStore) Lift(ctx context.Context, client string) (bool, error) {
+	n, err := s.rdb.Del(ctx, keys.Ban(client)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,178 @@
+// Package clientip resolves the address of the client behind trusted
+// reverse proxies.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Forwarding headers a Resolver can read.
+const (
+	XForwardedFor = "x-forwarded-for"
+	Forwarded     = "forwarded"
+	XRealIP       = "x-real-ip"
+)
+
+// Resolver finds a request's client address. Forwarding headers are only
+// believed when the connection comes from a trusted proxy, and are read
+// from the right, the hop nearest to us, stopping at the first address
+// that is not a trusted proxy: everything to its left was written by the
+// client and may be forged.
+type Resolver struct {
+	trusted []netip.Prefix
+	header  string
+}
+
+// NewResolver trusts proxies in the given CIDRs or single addresses and
+// reads header, one of XForwardedFor, Forwarded or XRealIP.
+func NewResolver(trusted []string, header string) (*Resolver, error) {
+	r := &Resolver{header: strings.ToLower(header)}
+	switch r.header {
+	case XForwardedFor, Forwarded, XRealIP:
+	default:
+		return nil, fmt.Errorf("unknown forwarding header %q", header)
+	}
+	for _, s := range trusted {
+		p, err := ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, p)
+	}
+	return r, nil
+}
+
+// ParsePrefix reads a CIDR or a single address.
+func ParsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return p.Masked(), nil
+	}
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", s)
+	}
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen()), nil
+}
+
+func (res *Resolver) isTrusted(a netip.Addr) bool {
+	for _, p := range res.trusted {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client address of r, or false when even the peer
+// address cannot be parsed.
+func (res *Resolver) Resolve(r *http.Request) (netip.Addr, bool) {
+	peer, ok := parseHost(r.RemoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	if !res.isTrusted(peer) {
+		return peer, true
+	}
+
+	hops := res.hops(r.Header)
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHost(hops[i])
+		if !ok {
+			// An unknown or obfuscated hop; the last address we can
+			// vouch for is as close to the client as we can get.
+			break
+		}
+		client = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return client, true
+}
+
+// hops lists the forwarded addresses in header order, leftmost first.
+func (res *Resolver) hops(h http.Header) []string {
+	var hops []string
+	switch res.header {
+	case XForwardedFor:
+		for _, v := range h.Values("X-Forwarded-For") {
+			for _, hop := range strings.Split(v, ",") {
+				hops = append(hops, strings.TrimSpace(hop))
+			}
+		}
+	case Forwarded:
+		for _, v := range h.Values("Forwarded") {
+			for _, elem := range strings.Split(v, ",") {
+				hops = append(hops, forwardedFor(elem))
+			}
+		}
+	case XRealIP:
+		if v := strings.TrimSpace(h.Get("X-Real-IP")); v != "" {
+			hops = append(hops, v)
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the for= parameter of one RFC 7239 element, e.g.
+// `for="[2001:db8::1]:4711";proto=https`, or "" when it has none.
+func forwardedFor(elem string) string {
+	for _, pair := range strings.Split(elem, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(name, "for") {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// parseHost reads an address with or without a port, IPv6 optionally in
+// brackets.
+func parseHost(s string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	a, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return a.Unmap(), true
+}
+
+// Group returns the network a client is counted under: the address itself
+// for IPv4 and its enclosing /bits for IPv6, since one host is commonly
+// given a whole /64.
+func Group(a netip.Addr, bits int) string {
+	if a.Is4() || bits >= 128 {
+		return a.String()
+	}
+	p, _ := a.Prefix(bits)
+	return p.String()
+}
+
+type addrKey struct{}
+
+// NewContext returns ctx carrying the client address.
+func NewContext(ctx context.Context, a netip.Addr) context.Context {
+	return context.WithValue(ctx, addrKey{}, a)
+}
+
+// FromContext returns the client address stored by NewContext.
+func FromContext(ctx context.Context) (netip.Addr, bool) {
+	a, ok := ctx.Value(addrKey{}).(netip.Addr)
+	return a, ok
+}
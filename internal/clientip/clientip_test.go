@@ -0,0 +1,174 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	trusted := []string{"10.0.0.0/8", "2001:db8:ffff::1"}
+	tests := []struct {
+		name    string
+		header  string
+		peer    string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "untrusted peer's spoofed X-Forwarded-For is ignored",
+			header: XForwardedFor, peer: "203.0.113.9:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:   "no forwarding header",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			want: "10.0.0.1",
+		},
+		{
+			name:   "one trusted hop",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "several trusted hops",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, 10.1.1.1", "10.2.2.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "spoofed left-most entry behind trusted hops",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.7, 10.1.1.1"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "chain made only of trusted proxies",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.3.3.3, 10.1.1.1"}},
+			want:    "10.3.3.3",
+		},
+		{
+			name:   "unparsable hop stops the walk",
+			header: XForwardedFor, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage, 10.1.1.1"}},
+			want:    "10.1.1.1",
+		},
+		{
+			name:   "Forwarded with a bracketed IPv6 address and port",
+			header: Forwarded, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8::1]:443";proto=https`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:   "Forwarded chain",
+			header: Forwarded, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for=1.2.3.4, For=198.51.100.7;by=10.0.0.1, for=10.1.1.1`}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "Forwarded obfuscated identifier",
+			header: Forwarded, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"Forwarded": {`for=_hidden, for=198.51.100.7`}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "X-Real-IP from a trusted peer",
+			header: XRealIP, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Real-IP": {" 198.51.100.7 "}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:   "X-Real-IP from an untrusted peer",
+			header: XRealIP, peer: "203.0.113.9:5000",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.7"}},
+			want:    "203.0.113.9",
+		},
+		{
+			name:   "other headers are not read",
+			header: XRealIP, peer: "10.0.0.1:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:   "trusted IPv6 peer",
+			header: XForwardedFor, peer: "[2001:db8:ffff::1]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"2001:db8::7"}},
+			want:    "2001:db8::7",
+		},
+		{
+			name:   "IPv4-mapped peer",
+			header: XForwardedFor, peer: "[::ffff:10.0.0.1]:5000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := NewResolver(trusted, tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			for k, vs := range tt.headers {
+				for _, v := range vs {
+					r.Header.Add(k, v)
+				}
+			}
+			got, ok := res.Resolve(r)
+			if !ok || got != netip.MustParseAddr(tt.want) {
+				t.Errorf("Resolve = %v, %t; want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveUnparsablePeer(t *testing.T) {
+	res, err := NewResolver(nil, XForwardedFor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "pipe"
+	if a, ok := res.Resolve(r); ok {
+		t.Errorf("Resolve = %v, want failure", a)
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	tests := []struct {
+		trusted []string
+		header  string
+	}{
+		{nil, "x-client-ip"},
+		{[]string{"10.0.0.0/33"}, XForwardedFor},
+		{[]string{"not-an-address"}, XForwardedFor},
+	}
+	for _, tt := range tests {
+		if _, err := NewResolver(tt.trusted, tt.header); err == nil {
+			t.Errorf("NewResolver(%q, %q) succeeded", tt.trusted, tt.header)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		addr string
+		bits int
+		want string
+	}{
+		{"198.51.100.7", 64, "198.51.100.7"},
+		{"2001:db8:1:2:3:4:5:6", 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:ffff::1", 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:3:4:5:6", 48, "2001:db8:1::/48"},
+		{"2001:db8:1:2:3:4:5:6", 128, "2001:db8:1:2:3:4:5:6"},
+	}
+	for _, tt := range tests {
+		if got := Group(netip.MustParseAddr(tt.addr), tt.bits); got != tt.want {
+			t.Errorf("Group(%s, %d) = %s, want %s", tt.addr, tt.bits, got, tt.want)
+		}
+	}
+}
@@ -1,22 +1,438 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML or TOML file, environment variables and command-line flags, in
+// increasing order of precedence.
+//
+// Every setting is a leaf field below Config. Its file key is the path of
+// yaml/toml names ("redis.pool_size"), its flag the same path with dashes
+// ("-redis.pool-size"), and its environment variable the env tag. Settings
+// tagged reload:"restart", directly or through their section, only take
+// effect on restart; see Reloader.
 package config
 
-import "os"
+import (
+	"time"
+)
+
+// Storage backends accepted in STORE_BACKEND.
+const (
+	StoreRedis  = "redis"
+	StoreMemory = "memory"
+)
+
+// Log formats accepted in LOG_FORMAT.
+const (
+	LogJSON = "json"
+	LogText = "text"
+)
+
+// Trace exporters accepted in TRACING_EXPORTER.
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Rate limiter backends accepted in RATE_LIMIT_BACKEND.
+const (
+	LimiterLocal = "local"
+	LimiterRedis = "redis"
+)
+
+// Redis deployment modes accepted in REDIS_MODE.
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
 
 type Config struct {
-    RedisAddr string
-    Port      string
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Store       StoreConfig       `yaml:"store" toml:"store" reload:"restart"`
+	Redis       RedisConfig       `yaml:"redis" toml:"redis" reload:"restart"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	ClientIP    ClientIPConfig    `yaml:"client_ip" toml:"client_ip"`
+	Access      AccessConfig      `yaml:"access" toml:"access"`
+	CORS        CORSConfig        `yaml:"cors" toml:"cors"`
+	Leaderboard LeaderboardConfig `yaml:"leaderboard" toml:"leaderboard"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Events      EventsConfig      `yaml:"events" toml:"events" reload:"restart"`
+	Features    FeaturesConfig    `yaml:"features" toml:"features"`
+	Admin       AdminConfig       `yaml:"admin" toml:"admin"`
+	Health      HealthConfig      `yaml:"health" toml:"health" reload:"restart"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing" reload:"restart"`
+	Logging     LoggingConfig     `yaml:"logging" toml:"logging"`
+}
+
+type ServerConfig struct {
+	Port string `yaml:"port" toml:"port" env:"PORT" reload:"restart"`
+	// RequestTimeout bounds each API request.
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout" env:"REQUEST_TIMEOUT"`
+	// ReadTimeout, WriteTimeout and IdleTimeout configure the http.Server.
+	// WriteTimeout must leave room for RequestTimeout.
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" reload:"restart"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" reload:"restart"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" reload:"restart"`
+	// DrainDelay is how long /health reports failure after SIGTERM before
+	// the listener closes, giving load balancers time to notice.
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"SERVER_DRAIN_DELAY"`
+	// ShutdownTimeout bounds the wait for in-flight requests and
+	// background work on shutdown.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+}
+
+type StoreConfig struct {
+	// Backend is StoreRedis or StoreMemory. The memory backend keeps
+	// everything in process and needs no Redis; data is lost on restart.
+	Backend string `yaml:"backend" toml:"backend" env:"STORE_BACKEND"`
+	// Board is the leaderboard's key, which also tags its derived keys.
+	Board string `yaml:"board" toml:"board" env:"LEADERBOARD_KEY"`
+}
+
+type RedisConfig struct {
+	// Mode is one of RedisStandalone, RedisSentinel or RedisCluster.
+	Mode string `yaml:"mode" toml:"mode" env:"REDIS_MODE"`
+	// Addrs lists the server for standalone mode, the sentinels for
+	// sentinel mode and the seed nodes for cluster mode.
+	Addrs      []string `yaml:"addrs" toml:"addrs" env:"REDIS_ADDRS,REDIS_ADDR"`
+	MasterName string   `yaml:"master_name" toml:"master_name" env:"REDIS_MASTER_NAME"`
+	Username   string   `yaml:"username" toml:"username" env:"REDIS_USERNAME"`
+	Password   string   `yaml:"password" toml:"password" env:"REDIS_PASSWORD" secret:"true"`
+	// DB must be 0 in cluster mode, which has no numbered databases.
+	DB  int            `yaml:"db" toml:"db" env:"REDIS_DB"`
+	TLS RedisTLSConfig `yaml:"tls" toml:"tls"`
+
+	PoolSize     int           `yaml:"pool_size" toml:"pool_size" env:"REDIS_POOL_SIZE"`
+	MinIdleConns int           `yaml:"min_idle_conns" toml:"min_idle_conns" env:"REDIS_MIN_IDLE_CONNS"`
+	PoolTimeout  time.Duration `yaml:"pool_timeout" toml:"pool_timeout" env:"REDIS_POOL_TIMEOUT"`
+	DialTimeout  time.Duration `yaml:"dial_timeout" toml:"dial_timeout" env:"REDIS_DIAL_TIMEOUT"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"REDIS_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"REDIS_WRITE_TIMEOUT"`
+	MaxRetries   int           `yaml:"max_retries" toml:"max_retries" env:"REDIS_MAX_RETRIES"`
 }
 
-func Load() *Config {
-    return &Config{
-        RedisAddr: getEnv("REDIS_ADDR", "localhost:6379"),
-        Port:      getEnv("PORT", "8080"),
-    }
+type RedisTLSConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"REDIS_TLS"`
+	// ServerName overrides the name verified against the server
+	// certificate.
+	ServerName string `yaml:"server_name" toml:"server_name" env:"REDIS_TLS_SERVER_NAME"`
+	// CAFile adds a PEM bundle to the system roots.
+	CAFile string `yaml:"ca_file" toml:"ca_file" env:"REDIS_TLS_CA_FILE"`
+	// CertFile and KeyFile enable client certificate authentication.
+	CertFile           string `yaml:"cert_file" toml:"cert_file" env:"REDIS_TLS_CERT_FILE"`
+	KeyFile            string `yaml:"key_file" toml:"key_file" env:"REDIS_TLS_KEY_FILE"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify" env:"REDIS_TLS_INSECURE_SKIP_VERIFY"`
 }
 
-func getEnv(key, fallback string) string {
-    if value := os.Getenv(key); value != "" {
-        return value
-    }
-    return fallback
-}
\ No newline at end of file
+type RateLimitConfig struct {
+	// Backend is LimiterLocal, limiting each replica on its own, or
+	// LimiterRedis, sharing one limit per client across replicas. The Redis
+	// limiter needs the Redis store and falls back to local limiting while
+	// Redis is unavailable.
+	Backend string `yaml:"backend" toml:"backend" env:"RATE_LIMIT_BACKEND" reload:"restart"`
+	// RedisTimeout bounds each Redis limiter call before falling back.
+	RedisTimeout time.Duration `yaml:"redis_timeout" toml:"redis_timeout" env:"RATE_LIMIT_REDIS_TIMEOUT" reload:"restart"`
+	// RequestsPerSecond and Burst make up the default policy, which limits
+	// every API request per client IP.
+	RequestsPerSecond int `yaml:"requests_per_second" toml:"requests_per_second" env:"RATE_LIMIT_RPS"`
+	Burst             int `yaml:"burst" toml:"burst" env:"RATE_LIMIT_BURST"`
+	// Policies adds limits per route, API key tier, player and leaderboard
+	// in ratelimit.ParsePolicies format.
+	Policies string `yaml:"policies" toml:"policies" env:"RATE_LIMIT_POLICIES"`
+	// APIKeys lists KEY=TIER[:PLAYER] entries. A request presenting a key in
+	// X-API-Key gets its tier and, when set, is authenticated as the player.
+	APIKeys []string `yaml:"api_keys" toml:"api_keys" env:"RATE_LIMIT_API_KEYS" secret:"true"`
+	// LegacyHeaders sends X-RateLimit-Limit, -Remaining and -Reset next to
+	// the standard RateLimit-Policy and RateLimit headers, for clients
+	// written against them.
+	LegacyHeaders bool `yaml:"legacy_headers" toml:"legacy_headers" env:"RATE_LIMIT_LEGACY_HEADERS"`
+	// CleanupInterval is how often idle clients are forgotten.
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"RATE_LIMIT_CLEANUP_INTERVAL" reload:"restart"`
+}
+
+// ClientIPConfig controls how the client address used for rate limiting
+// and logging is found behind reverse proxies.
+type ClientIPConfig struct {
+	// TrustedProxies lists the CIDRs or addresses of proxies whose
+	// forwarding header is believed. When empty the connection's address
+	// is always used and forwarding headers are ignored.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"CLIENT_IP_TRUSTED_PROXIES"`
+	// Header is the forwarding header the proxies set: x-forwarded-for,
+	// forwarded (RFC 7239) or x-real-ip.
+	Header string `yaml:"header" toml:"header" env:"CLIENT_IP_HEADER"`
+	// IPv6Prefix is the prefix length IPv6 clients are grouped by, since
+	// one host usually owns a whole /64.
+	IPv6Prefix int `yaml:"ipv6_prefix" toml:"ipv6_prefix" env:"CLIENT_IP_IPV6_PREFIX"`
+}
+
+// AccessConfig decides which clients may use the API.
+type AccessConfig struct {
+	// Allow and Deny list CIDRs or addresses. The most specific match
+	// wins, Deny on a tie. An empty Allow lets every client not denied
+	// through; otherwise only the clients it matches are served.
+	Allow []string `yaml:"allow" toml:"allow" env:"ACCESS_ALLOW"`
+	Deny  []string `yaml:"deny" toml:"deny" env:"ACCESS_DENY"`
+	// Exempt lists CIDRs or addresses never rate limited or banned, such as
+	// internal services. It does not let denied clients through.
+	Exempt []string `yaml:"exempt" toml:"exempt" env:"ACCESS_EXEMPT"`
+	// BanAfter rate limit violations within BanWindow ban a client for
+	// BanDuration. Bans are kept in Redis with the Redis store, so every
+	// replica honours them. 0, the default, disables bans.
+	BanAfter    int           `yaml:"ban_after" toml:"ban_after" env:"ACCESS_BAN_AFTER"`
+	BanWindow   time.Duration `yaml:"ban_window" toml:"ban_window" env:"ACCESS_BAN_WINDOW"`
+	BanDuration time.Duration `yaml:"ban_duration" toml:"ban_duration" env:"ACCESS_BAN_DURATION"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string `yaml:"allowed_methods" toml:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders []string `yaml:"allowed_headers" toml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+}
+
+type LeaderboardConfig struct {
+	// DefaultLimit and MaxLimit apply to every limit parameter.
+	DefaultLimit  int `yaml:"default_limit" toml:"default_limit" env:"LEADERBOARD_DEFAULT_LIMIT"`
+	MaxLimit      int `yaml:"max_limit" toml:"max_limit" env:"LEADERBOARD_MAX_LIMIT"`
+	DefaultRadius int `yaml:"default_radius" toml:"default_radius" env:"LEADERBOARD_DEFAULT_RADIUS"`
+	MaxRadius     int `yaml:"max_radius" toml:"max_radius" env:"LEADERBOARD_MAX_RADIUS"`
+	// IdempotencyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl" env:"IDEMPOTENCY_TTL"`
+	// Tiers holds per-leaderboard tier definitions in tiers.ParseSet format.
+	// Empty, the default, defines no tiers.
+	Tiers string `yaml:"tiers" toml:"tiers" env:"TIERS"`
+}
+
+// CacheConfig controls the response cache of leaderboard reads. Score
+// writes invalidate the entries they affect, so the TTLs only bound how
+// long entries are kept. The cache is off by default.
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"CACHE_ENABLED"`
+	// TTL applies to responses drawn from the whole board, which any write
+	// invalidates.
+	TTL time.Duration `yaml:"ttl" toml:"ttl" env:"CACHE_TTL"`
+	// PlayerTTL applies to a single player's score, which only that
+	// player's writes invalidate.
+	PlayerTTL time.Duration `yaml:"player_ttl" toml:"player_ttl" env:"CACHE_PLAYER_TTL"`
+	// BypassHeader, when present on a request, skips the cache.
+	BypassHeader string `yaml:"bypass_header" toml:"bypass_header" env:"CACHE_BYPASS_HEADER"`
+	// StaleWhileRevalidate is how long an expired entry is still served
+	// while a single request refreshes it.
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate" toml:"stale_while_revalidate" env:"CACHE_STALE_WHILE_REVALIDATE"`
+	// StaleAfterWrite is how long after a write an entry it invalidated is
+	// still served while a single request refreshes it. 0 serves writes
+	// at once.
+	StaleAfterWrite time.Duration `yaml:"stale_after_write" toml:"stale_after_write" env:"CACHE_STALE_AFTER_WRITE"`
+	// StaleIfError is how long past expiry an entry is served in place of
+	// a server error, or while the cache cannot be read.
+	StaleIfError time.Duration `yaml:"stale_if_error" toml:"stale_if_error" env:"CACHE_STALE_IF_ERROR"`
+	// LockTimeout bounds how long other replicas wait for the one filling
+	// an entry. 0 lets every replica fill entries on its own.
+	LockTimeout time.Duration `yaml:"lock_timeout" toml:"lock_timeout" env:"CACHE_LOCK_TIMEOUT"`
+	// EarlyExpiration scales the random early refresh of entries nearing
+	// expiry; 0 disables it.
+	EarlyExpiration float64 `yaml:"early_expiration" toml:"early_expiration" env:"CACHE_EARLY_EXPIRATION"`
+	// Local keeps recently served entries in process in front of the store.
+	Local LocalCacheConfig `yaml:"local" toml:"local" reload:"restart"`
+}
+
+// LocalCacheConfig controls the in-process cache layer. Writes on any
+// replica invalidate its entries through the store, which announces them
+// over Redis Pub/Sub with the Redis backend.
+type LocalCacheConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"CACHE_LOCAL_ENABLED"`
+	// MaxBytes bounds the size of the entries held, least recently used
+	// going first.
+	MaxBytes int `yaml:"max_bytes" toml:"max_bytes" env:"CACHE_LOCAL_MAX_BYTES"`
+	// TTL bounds how long an entry is served locally, and so how stale it
+	// can get should an invalidation be lost.
+	TTL time.Duration `yaml:"ttl" toml:"ttl" env:"CACHE_LOCAL_TTL"`
+}
+
+type EventsConfig struct {
+	Channel string `yaml:"channel" toml:"channel" env:"EVENTS_CHANNEL"`
+	Stream  string `yaml:"stream" toml:"stream" env:"EVENTS_STREAM"`
+	// QueueSize bounds events waiting for delivery; more are dropped.
+	QueueSize int `yaml:"queue_size" toml:"queue_size" env:"EVENTS_QUEUE_SIZE"`
+}
+
+// FeaturesConfig switches optional behaviour on and off.
+type FeaturesConfig struct {
+	// Docs serves /openapi.json and /docs.
+	Docs bool `yaml:"docs" toml:"docs" env:"FEATURE_DOCS"`
+	// TierEvents publishes an event when a submission changes the
+	// submitting player's tier. In percentile mode a submission can also
+	// move other players across a boundary; no events are sent for them.
+	TierEvents bool `yaml:"tier_events" toml:"tier_events" env:"FEATURE_TIER_EVENTS"`
+	// Metrics serves Prometheus metrics at /metrics.
+	Metrics bool `yaml:"metrics" toml:"metrics" env:"FEATURE_METRICS"`
+}
+
+type AdminConfig struct {
+	// Token guards the /admin endpoints, which are disabled when empty.
+	Token string `yaml:"token" toml:"token" env:"ADMIN_TOKEN" secret:"true"`
+}
+
+// HealthConfig tunes the readiness checks behind /readyz and /health.
+type HealthConfig struct {
+	// CacheTTL is how long check results are reused between probes.
+	CacheTTL     time.Duration `yaml:"cache_ttl" toml:"cache_ttl" env:"HEALTH_CACHE_TTL"`
+	CheckTimeout time.Duration `yaml:"check_timeout" toml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// MaxPingLatency above which Redis is reported degraded.
+	MaxPingLatency time.Duration `yaml:"max_ping_latency" toml:"max_ping_latency" env:"HEALTH_MAX_PING_LATENCY"`
+	// MaxPoolUsage is the share of pooled connections in use, from 0 to 1,
+	// at which the pool is reported degraded.
+	MaxPoolUsage float64 `yaml:"max_pool_usage" toml:"max_pool_usage" env:"HEALTH_MAX_POOL_USAGE"`
+	// MaxReplicationLag above which replicas are reported degraded.
+	MaxReplicationLag time.Duration `yaml:"max_replication_lag" toml:"max_replication_lag" env:"HEALTH_MAX_REPLICATION_LAG"`
+}
+
+// TracingConfig controls OpenTelemetry tracing. Trace context is taken
+// from and passed on in W3C traceparent headers.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled" env:"TRACING_ENABLED"`
+	// Exporter is ExporterOTLP, sending OTLP over HTTP to Endpoint, or
+	// ExporterStdout, printing spans for local debugging.
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	// Endpoint is the collector's host:port.
+	Endpoint string `yaml:"endpoint" toml:"endpoint" env:"TRACING_ENDPOINT"`
+	// Insecure sends to the collector over plain HTTP.
+	Insecure    bool   `yaml:"insecure" toml:"insecure" env:"TRACING_INSECURE"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+	// SampleRatio is the share of new traces recorded, from 0 to 1. Requests
+	// carrying a sampled parent are always recorded.
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"`
+}
+
+type LoggingConfig struct {
+	// Format is LogJSON or LogText.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" reload:"restart"`
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			RequestTimeout:  30 * time.Second,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    35 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			DrainDelay:      5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Store: StoreConfig{
+			Backend: StoreRedis,
+			Board:   "scores",
+		},
+		Redis: RedisConfig{
+			Mode:  RedisStandalone,
+			Addrs: []string{"localhost:6379"},
+		},
+		RateLimit: RateLimitConfig{
+			Backend:           LimiterLocal,
+			RedisTimeout:      50 * time.Millisecond,
+			RequestsPerSecond: 60,
+			Burst:             10,
+			LegacyHeaders:     true,
+			CleanupInterval:   time.Minute,
+		},
+		ClientIP: ClientIPConfig{
+			Header:     "x-forwarded-for",
+			IPv6Prefix: 64,
+		},
+		Access: AccessConfig{
+			BanWindow:   time.Minute,
+			BanDuration: 15 * time.Minute,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+		},
+		Leaderboard: LeaderboardConfig{
+			DefaultLimit:   10,
+			MaxLimit:       100,
+			DefaultRadius:  2,
+			MaxRadius:      10,
+			IdempotencyTTL: 2 * time.Minute,
+		},
+		Cache: CacheConfig{
+			TTL:          10 * time.Second,
+			PlayerTTL:    time.Minute,
+			BypassHeader: "X-Cache-Bypass",
+
+			StaleWhileRevalidate: 5 * time.Second,
+			StaleIfError:         time.Minute,
+			LockTimeout:          2 * time.Second,
+			EarlyExpiration:      1,
+			Local: LocalCacheConfig{
+				MaxBytes: 32 << 20,
+				TTL:      2 * time.Second,
+			},
+		},
+		Events: EventsConfig{
+			Channel:   "events:leaderboard",
+			Stream:    "events:leaderboard:log",
+			QueueSize: 1024,
+		},
+		Features: FeaturesConfig{
+			Docs:    true,
+			Metrics: true,
+		},
+		Health: HealthConfig{
+			CacheTTL:          2 * time.Second,
+			CheckTimeout:      time.Second,
+			MaxPingLatency:    100 * time.Millisecond,
+			MaxPoolUsage:      0.9,
+			MaxReplicationLag: 10 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    ExporterOTLP,
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "go-redis-leaderboard",
+			SampleRatio: 1,
+		},
+		Logging: LoggingConfig{
+			Format: LogJSON,
+			Level:  "info",
+		},
+	}
+}
+
+// Load builds the configuration from args (usually os.Args[1:]) and the
+// environment and validates it. The file is named by -config or
+// CONFIG_FILE; without either only defaults, env and flags apply.
+func Load(args []string) (*Config, error) {
+	cfg, _, err := load(args)
+	return cfg, err
+}
+
+// load is Load that also returns the config file path, if any.
+func load(args []string) (*Config, string, error) {
+	fs, flags := newFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	cfg := Default()
+	path := flags.configPath
+	if path == "" {
+		path = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, cfg); err != nil {
+			return nil, path, err
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, path, err
+	}
+	if err := flags.apply(cfg); err != nil {
+		return nil, path, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, path, err
+	}
+	return cfg, path, nil
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a config file named name into a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  port: "1111"
+rate_limit:
+  burst: 5
+access:
+  deny: [10.0.0.0/8]
+`)
+	tomlFile := writeFile(t, "config.toml", `
+[server]
+port = "1111"
+
+[rate_limit]
+burst = 5
+`)
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		port  string
+		burst int
+		deny  []string
+	}{
+		{name: "defaults", port: "8080", burst: Default().RateLimit.Burst},
+		{name: "file over defaults", args: []string{"-config", yamlFile}, port: "1111", burst: 5, deny: []string{"10.0.0.0/8"}},
+		{name: "file named by CONFIG_FILE", env: map[string]string{"CONFIG_FILE": yamlFile}, port: "1111", burst: 5, deny: []string{"10.0.0.0/8"}},
+		{name: "-config over CONFIG_FILE", env: map[string]string{"CONFIG_FILE": yamlFile}, args: []string{"-config", tomlFile}, port: "1111", burst: 5},
+		{
+			name: "env over file",
+			env:  map[string]string{"PORT": "2222", "ACCESS_DENY": "192.0.2.0/24, 198.51.100.1,"},
+			args: []string{"-config", yamlFile},
+			port: "2222", burst: 5, deny: []string{"192.0.2.0/24", "198.51.100.1"},
+		},
+		{name: "empty env is ignored", env: map[string]string{"PORT": " "}, args: []string{"-config", yamlFile}, port: "1111", burst: 5, deny: []string{"10.0.0.0/8"}},
+		{
+			name: "flags over env",
+			env:  map[string]string{"PORT": "2222", "RATE_LIMIT_BURST": "7"},
+			args: []string{"-config", yamlFile, "-server.port", "3333"},
+			port: "3333", burst: 7, deny: []string{"10.0.0.0/8"},
+		},
+		{
+			name: "flags over env whatever their position",
+			env:  map[string]string{"PORT": "2222"},
+			args: []string{"-server.port=3333", "-access.deny=192.0.2.1", "-config", yamlFile},
+			port: "3333", burst: 5, deny: []string{"192.0.2.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"CONFIG_FILE", "PORT", "RATE_LIMIT_BURST", "ACCESS_DENY"} {
+				t.Setenv(name, tt.env[name])
+			}
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.port || cfg.RateLimit.Burst != tt.burst || !reflect.DeepEqual(cfg.Access.Deny, tt.deny) {
+				t.Errorf("port %s, burst %d, deny %q; want %s, %d, %q",
+					cfg.Server.Port, cfg.RateLimit.Burst, cfg.Access.Deny, tt.port, tt.burst, tt.deny)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "unknown yaml key", args: []string{"-config", writeFile(t, "c.yaml", "server:\n  prot: \"1\"\n")}, want: "field prot not found"},
+		{name: "unknown toml key", args: []string{"-config", writeFile(t, "c.toml", "[server]\nprot = \"1\"\n")}, want: "unknown keys server.prot"},
+		{name: "unsupported extension", args: []string{"-config", writeFile(t, "c.json", "{}")}, want: `unsupported extension ".json"`},
+		{name: "missing file", args: []string{"-config", "/nonexistent/c.yaml"}, want: "config file:"},
+		{name: "bad env value", env: map[string]string{"RATE_LIMIT_BURST": "lots"}, want: `RATE_LIMIT_BURST: invalid integer "lots"`},
+		{name: "bad flag value", args: []string{"-server.request-timeout=soon"}, want: `flag -server.request-timeout: invalid duration "soon"`},
+		{name: "unknown flag", args: []string{"-no-such-flag"}, want: "flag provided but not defined"},
+		{name: "invalid setting", args: []string{"-rate-limit.burst=0"}, want: "rate_limit.burst: must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("RATE_LIMIT_BURST", tt.env["RATE_LIMIT_BURST"])
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("the defaults are invalid: %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"port", func(c *Config) { c.Server.Port = "http" }, []string{`server.port: must be a port number, got "http"`}},
+		{"write timeout", func(c *Config) { c.Server.WriteTimeout = c.Server.RequestTimeout },
+			[]string{"server.write_timeout: must be 0 (none) or longer than request_timeout (30s)"}},
+		{"redis limiter without redis", func(c *Config) { c.Store.Backend, c.RateLimit.Backend = StoreMemory, LimiterRedis },
+			[]string{"rate_limit.backend: redis needs store.backend redis"}},
+		{"redis address", func(c *Config) { c.Redis.Addrs = []string{"localhost"} },
+			[]string{`redis.addrs: "localhost" must be host:port`}},
+		{"policy tier", func(c *Config) { c.RateLimit.Policies = "name=gold tiers=gold limits=1/s" },
+			[]string{`rate_limit.policies: policy gold: no API key has tier "gold"`}},
+		{"access lists", func(c *Config) {
+			c.Access.Allow, c.Access.Deny, c.Access.Exempt = []string{"a"}, []string{"b"}, []string{"c"}
+		}, []string{"access.allow: ", "access.deny: ", "access.exempt: "}},
+		{"ban settings only checked when banning", func(c *Config) { c.Access.BanWindow = 0 }, nil},
+		{"ban window", func(c *Config) { c.Access.BanAfter, c.Access.BanWindow = 3, 0 }, []string{"access.ban_window: must be positive"}},
+		{"cors origin", func(c *Config) { c.CORS.AllowedOrigins = []string{"example.com"} },
+			[]string{`cors.allowed_origins: "example.com" must be * or scheme://host[:port]`}},
+		{"default limit", func(c *Config) { c.Leaderboard.DefaultLimit = c.Leaderboard.MaxLimit + 1 },
+			[]string{"leaderboard.default_limit: must be within 1..max_limit"}},
+		{"cache settings only checked when caching", func(c *Config) { c.Cache.Enabled, c.Cache.TTL = false, 0 }, nil},
+		{"admin token", func(c *Config) { c.Admin.Token = "short" }, []string{"admin.token: must be at least 16 characters"}},
+		{"every error at once", func(c *Config) {
+			c.Server.Port, c.Logging.Level, c.Tracing.SampleRatio = "0", "loud", 2
+		}, []string{"server.port: ", "tracing.sample_ratio: must be within [0, 1]", `logging.level: must be debug, info, warn or error, got "loud"`}},
+	}
+	for _, tt := range tests {
+		c := Default()
+		tt.change(c)
+		err := c.Validate()
+		if len(tt.want) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(tt.want) {
+			t.Errorf("%s: got %d errors, want %d:\n%v", tt.name, len(lines), len(tt.want), err)
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.HasPrefix(lines[i], want) {
+				t.Errorf("%s: error %q, want %q", tt.name, lines[i], want)
+			}
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	c := Default()
+	c.Redis.Password = "hunter2"
+	c.Server.RequestTimeout = 1500 * time.Millisecond
+	got := c.Redacted()
+	section := func(name string) map[string]interface{} { return got[name].(map[string]interface{}) }
+
+	if v := section("redis")["password"]; v != redacted {
+		t.Errorf("redis.password = %v", v)
+	}
+	if v := section("admin")["token"]; v != "" {
+		t.Errorf("empty admin.token = %v", v)
+	}
+	if v, ok := section("rate_limit")["api_keys"].([]string); !ok || len(v) != 0 {
+		t.Errorf("empty rate_limit.api_keys = %#v", v)
+	}
+	if v := section("server")["request_timeout"]; v != "1.5s" {
+		t.Errorf("server.request_timeout = %v", v)
+	}
+
+	c.RateLimit.APIKeys = []string{"k=gold"}
+	if v := c.Redacted()["rate_limit"].(map[string]interface{})["api_keys"]; v != redacted {
+		t.Errorf("rate_limit.api_keys = %v", v)
+	}
+}
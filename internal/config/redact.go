@@ -0,0 +1,50 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of non-empty secrets.
+const redacted = "[REDACTED]"
+
+// Redacted returns the configuration as nested maps keyed like the config
+// file, with secrets masked and durations in time.Duration notation. It is
+// meant for display, e.g. on the admin endpoint.
+func (c *Config) Redacted() map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, f := range fields(c) {
+		var v interface{} = f.value.Interface()
+		switch {
+		case f.secret:
+			if !empty(f.value) {
+				v = redacted
+			}
+		case f.value.Type() == durationType:
+			v = time.Duration(f.value.Int()).String()
+		}
+
+		parts := strings.Split(f.path, ".")
+		m := out
+		for _, p := range parts[:len(parts)-1] {
+			next, ok := m[p].(map[string]interface{})
+			if !ok {
+				next = map[string]interface{}{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	return out
+}
+
+// empty reports whether a secret is unset: an empty string, list or map.
+func empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.String() == ""
+}
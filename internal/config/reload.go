@@ -0,0 +1,129 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader holds the current configuration and replaces it when the config
+// file changes or Reload is called. A reload that fails to load or validate
+// is rejected and the current configuration kept. Settings that need a
+// restart keep their running values, with a warning, until then.
+type Reloader struct {
+	args    []string
+	path    string
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader loads the initial configuration as Load does.
+func NewReloader(args []string) (*Reloader, error) {
+	cfg, path, err := load(args)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reloader{args: args, path: path}
+	r.current.Store(cfg)
+	return r, nil
+}
+
+// Config returns the current configuration. Callers must not modify it.
+func (r *Reloader) Config() *Config {
+	return r.current.Load()
+}
+
+// OnReload registers fn to be called with each accepted configuration.
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload reads the file, environment and flags again.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, _, err := load(r.args)
+	if err != nil {
+		log.Printf("[config] reload rejected, keeping current configuration: %v", err)
+		return err
+	}
+
+	cur := r.current.Load()
+	var changed, pinned []string
+	nextFields, curFields := fields(next), fields(cur)
+	for i, f := range nextFields {
+		old := curFields[i].value
+		if reflect.DeepEqual(f.value.Interface(), old.Interface()) {
+			continue
+		}
+		if f.restart {
+			f.value.Set(old)
+			pinned = append(pinned, f.path)
+			continue
+		}
+		changed = append(changed, f.path)
+	}
+	if len(pinned) > 0 {
+		log.Printf("[config] restart required to apply %s", strings.Join(pinned, ", "))
+	}
+	if len(changed) == 0 {
+		log.Printf("[config] reload: no changes")
+		return nil
+	}
+
+	r.current.Store(next)
+	for _, fn := range r.listeners {
+		fn(next)
+	}
+	log.Printf("[config] reloaded: %s", strings.Join(changed, ", "))
+	return nil
+}
+
+// Watch reloads whenever the config file's contents change, checking every
+// interval until ctx is done. It returns at once when no file is in use.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if r.path == "" {
+		return
+	}
+	last, _ := os.ReadFile(r.path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			data, err := os.ReadFile(r.path)
+			if err != nil {
+				// Editors may briefly remove the file while saving.
+				continue
+			}
+			if bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			r.Reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// String describes where the configuration is read from.
+func (r *Reloader) String() string {
+	if r.path == "" {
+		return "defaults, environment and flags"
+	}
+	return fmt.Sprintf("%s, environment and flags", r.path)
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// field is one leaf setting of a Config.
+type field struct {
+	// path is the dotted file key, e.g. "redis.pool_size".
+	path   string
+	env    []string
+	secret bool
+	// restart marks settings a reload cannot change.
+	restart bool
+	value   reflect.Value
+}
+
+// fields lists the settings of cfg in declaration order. The values are
+// addressable, so setting them updates cfg.
+func fields(cfg *Config) []field {
+	var out []field
+	walk(reflect.ValueOf(cfg).Elem(), "", false, &out)
+	return out
+}
+
+func walk(v reflect.Value, prefix string, restart bool, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		path := sf.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		restart := restart || sf.Tag.Get("reload") == "restart"
+		if sf.Type.Kind() == reflect.Struct {
+			walk(v.Field(i), path, restart, out)
+			continue
+		}
+		f := field{path: path, secret: sf.Tag.Get("secret") == "true", restart: restart, value: v.Field(i)}
+		if env := sf.Tag.Get("env"); env != "" {
+			f.env = strings.Split(env, ",")
+		}
+		*out = append(*out, f)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set parses s into the field. Lists are comma-separated.
+func (f field) set(s string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(splitList(s)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func flagName(path string) string {
+	return strings.ReplaceAll(path, "_", "-")
+}
+
+// flagValues collects flags during parsing so they can be applied after the
+// file and environment, whatever their position on the command line.
+type flagValues struct {
+	configPath string
+	set        map[string]string
+}
+
+func newFlagSet() (*flag.FlagSet, *flagValues) {
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	values := &flagValues{set: map[string]string{}}
+	fs.StringVar(&values.configPath, "config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	for _, f := range fields(Default()) {
+		path := f.path
+		usage := "sets " + path
+		if len(f.env) > 0 {
+			usage += " (env " + f.env[0] + ")"
+		}
+		fs.Func(flagName(path), usage, func(s string) error {
+			values.set[path] = s
+			return nil
+		})
+	}
+	return fs, values
+}
+
+func (v *flagValues) apply(cfg *Config) error {
+	for _, f := range fields(cfg) {
+		if s, ok := v.set[f.path]; ok {
+			if err := f.set(s); err != nil {
+				return fmt.Errorf("flag -%s: %w", flagName(f.path), err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnv sets every field whose environment variable is non-empty. When a
+// field lists several variables the first one set wins.
+func applyEnv(cfg *Config) error {
+	for _, f := range fields(cfg) {
+		for _, name := range f.env {
+			s := lookupEnv(name)
+			if s == "" {
+				continue
+			}
+			if err := f.set(s); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func lookupEnv(name string) string {
+	return strings.TrimSpace(os.Getenv(name))
+}
+
+// loadFile decodes a .yaml, .yml or .toml file over cfg. Keys that do not
+// match a setting are rejected so typos do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, k := range undecoded {
+				keys[i] = k.String()
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("config file %s: unknown keys %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q, want .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-redis/internal/clientip"
+	"go-redis/internal/ratelimit"
+	"go-redis/internal/tiers"
+)
+
+// Validate reports every invalid setting at once, one per line, each
+// prefixed with its file key.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, path, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port", "must be a port number, got %q", c.Server.Port)
+	check(c.Server.RequestTimeout > 0, "server.request_timeout", "must be positive")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout", "must not be negative")
+	check(c.Server.WriteTimeout == 0 || c.Server.WriteTimeout > c.Server.RequestTimeout,
+		"server.write_timeout", "must be 0 (none) or longer than request_timeout (%s)", c.Server.RequestTimeout)
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout", "must not be negative")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+
+	check(c.Store.Backend == StoreRedis || c.Store.Backend == StoreMemory,
+		"store.backend", "must be %s or %s, got %q", StoreRedis, StoreMemory, c.Store.Backend)
+	check(c.Store.Board != "", "store.board", "must not be empty")
+	check(!strings.ContainsAny(c.Store.Board, "{}"), "store.board", "must not contain braces, which would break hash tags")
+
+	if c.Store.Backend == StoreRedis {
+		errs = append(errs, c.Redis.validate()...)
+	}
+
+	rl := c.RateLimit
+	check(rl.Backend == LimiterLocal || rl.Backend == LimiterRedis,
+		"rate_limit.backend", "must be %s or %s, got %q", LimiterLocal, LimiterRedis, rl.Backend)
+	check(rl.Backend != LimiterRedis || c.Store.Backend == StoreRedis,
+		"rate_limit.backend", "%s needs store.backend %s", LimiterRedis, StoreRedis)
+	check(rl.RedisTimeout > 0, "rate_limit.redis_timeout", "must be positive")
+	check(rl.RequestsPerSecond > 0, "rate_limit.requests_per_second", "must be positive")
+	check(rl.Burst >= 1, "rate_limit.burst", "must be at least 1")
+	check(rl.CleanupInterval > 0, "rate_limit.cleanup_interval", "must be positive")
+	apiKeys, err := ratelimit.ParseAPIKeys(rl.APIKeys)
+	check(err == nil, "rate_limit.api_keys", "%v", err)
+	policies, err := ratelimit.ParsePolicies(rl.Policies)
+	check(err == nil, "rate_limit.policies", "%v", err)
+	knownTiers := map[string]bool{ratelimit.Anonymous: true}
+	for _, k := range apiKeys {
+		knownTiers[k.Tier] = true
+	}
+	for _, p := range policies {
+		for _, t := range p.Tiers {
+			check(knownTiers[t], "rate_limit.policies", "policy %s: no API key has tier %q", p.Name, t)
+		}
+		for _, b := range p.Boards {
+			check(b == c.Store.Board, "rate_limit.policies", "policy %s: unknown board %q", p.Name, b)
+		}
+	}
+
+	ip := c.ClientIP
+	for _, p := range ip.TrustedProxies {
+		_, err := clientip.ParsePrefix(p)
+		check(err == nil, "client_ip.trusted_proxies", "%v", err)
+	}
+	_, err = clientip.NewResolver(nil, ip.Header)
+	check(err == nil, "client_ip.header", "must be %s, %s or %s, got %q",
+		clientip.XForwardedFor, clientip.Forwarded, clientip.XRealIP, ip.Header)
+	check(ip.IPv6Prefix >= 1 && ip.IPv6Prefix <= 128, "client_ip.ipv6_prefix", "must be within 1..128")
+
+	a := c.Access
+	for _, p := range a.Allow {
+		_, err := clientip.ParsePrefix(p)
+		check(err == nil, "access.allow", "%v", err)
+	}
+	for _, p := range a.Deny {
+		_, err := clientip.ParsePrefix(p)
+		check(err == nil, "access.deny", "%v", err)
+	}
+	for _, p := range a.Exempt {
+		_, err := clientip.ParsePrefix(p)
+		check(err == nil, "access.exempt", "%v", err)
+	}
+	check(a.BanAfter >= 0, "access.ban_after", "must not be negative")
+	if a.BanAfter > 0 {
+		check(a.BanWindow > 0, "access.ban_window", "must be positive")
+		check(a.BanDuration >= time.Second, "access.ban_duration", "must be at least 1s")
+	}
+
+	check(len(c.CORS.AllowedOrigins) > 0, "cors.allowed_origins", "must not be empty")
+	for _, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		check(err == nil && u.Scheme != "" && u.Host != "" && u.Path == "",
+			"cors.allowed_origins", "%q must be * or scheme://host[:port]", o)
+	}
+	for _, m := range c.CORS.AllowedMethods {
+		check(m != "" && m == strings.ToUpper(m), "cors.allowed_methods", "%q must be an upper-case method", m)
+	}
+
+	lb := c.Leaderboard
+	check(lb.MaxLimit >= 1, "leaderboard.max_limit", "must be at least 1")
+	check(lb.DefaultLimit >= 1 && lb.DefaultLimit <= lb.MaxLimit,
+		"leaderboard.default_limit", "must be within 1..max_limit (%d)", lb.MaxLimit)
+	check(lb.MaxRadius >= 1, "leaderboard.max_radius", "must be at least 1")
+	check(lb.DefaultRadius >= 1 && lb.DefaultRadius <= lb.MaxRadius,
+		"leaderboard.default_radius", "must be within 1..max_radius (%d)", lb.MaxRadius)
+	check(lb.IdempotencyTTL > 0, "leaderboard.idempotency_ttl", "must be positive")
+	if _, err := tiers.ParseSet(lb.Tiers); err != nil {
+		check(false, "leaderboard.tiers", "%v", err)
+	}
+
+	if c.Cache.Enabled {
+		check(c.Cache.TTL >= time.Second, "cache.ttl", "must be at least 1s")
+		check(c.Cache.PlayerTTL >= time.Second, "cache.player_ttl", "must be at least 1s")
+		check(c.Cache.StaleWhileRevalidate >= 0, "cache.stale_while_revalidate", "must not be negative")
+		check(c.Cache.StaleAfterWrite >= 0, "cache.stale_after_write", "must not be negative")
+		check(c.Cache.StaleIfError >= 0, "cache.stale_if_error", "must not be negative")
+		check(c.Cache.LockTimeout >= 0, "cache.lock_timeout", "must not be negative")
+		check(c.Cache.EarlyExpiration >= 0, "cache.early_expiration", "must not be negative")
+		if c.Cache.Local.Enabled {
+			check(c.Cache.Local.MaxBytes > 0, "cache.local.max_bytes", "must be positive")
+			check(c.Cache.Local.TTL > 0, "cache.local.ttl", "must be positive")
+		}
+	}
+
+	check(c.Events.Channel != "", "events.channel", "must not be empty")
+	check(c.Events.Stream != "", "events.stream", "must not be empty")
+	check(c.Events.QueueSize >= 1, "events.queue_size", "must be at least 1")
+
+	check(c.Admin.Token == "" || len(c.Admin.Token) >= 16, "admin.token", "must be at least 16 characters")
+
+	h := c.Health
+	check(h.CacheTTL >= 0, "health.cache_ttl", "must not be negative")
+	check(h.CheckTimeout > 0, "health.check_timeout", "must be positive")
+	check(h.MaxPingLatency > 0, "health.max_ping_latency", "must be positive")
+	check(h.MaxPoolUsage > 0 && h.MaxPoolUsage <= 1, "health.max_pool_usage", "must be within (0, 1]")
+	check(h.MaxReplicationLag > 0, "health.max_replication_lag", "must be positive")
+
+	t := c.Tracing
+	if t.Enabled {
+		check(t.Exporter == ExporterOTLP || t.Exporter == ExporterStdout,
+			"tracing.exporter", "must be %s or %s, got %q", ExporterOTLP, ExporterStdout, t.Exporter)
+		if t.Exporter == ExporterOTLP {
+			_, port, err := net.SplitHostPort(t.Endpoint)
+			check(err == nil && port != "", "tracing.endpoint", "%q must be host:port", t.Endpoint)
+		}
+		check(t.ServiceName != "", "tracing.service_name", "must not be empty")
+	}
+	check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sample_ratio", "must be within [0, 1]")
+
+	check(c.Logging.Format == LogJSON || c.Logging.Format == LogText,
+		"logging.format", "must be %s or %s, got %q", LogJSON, LogText, c.Logging.Format)
+	var level slog.Level
+	check(level.UnmarshalText([]byte(strings.ToUpper(c.Logging.Level))) == nil,
+		"logging.level", "must be debug, info, warn or error, got %q", c.Logging.Level)
+
+	return errors.Join(errs...)
+}
+
+func (r RedisConfig) validate() []error {
+	var errs []error
+	check := func(ok bool, path, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("redis.%s: %s", path, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	switch r.Mode {
+	case RedisStandalone:
+		check(len(r.Addrs) == 1, "addrs", "standalone mode takes exactly one address, got %d", len(r.Addrs))
+	case RedisSentinel:
+		check(len(r.Addrs) > 0, "addrs", "must list at least one sentinel")
+		check(r.MasterName != "", "master_name", "is required in sentinel mode")
+	case RedisCluster:
+		check(len(r.Addrs) > 0, "addrs", "must list at least one seed node")
+		check(r.DB == 0, "db", "must be 0 in cluster mode")
+	default:
+		check(false, "mode", "must be %s, %s or %s, got %q", RedisStandalone, RedisSentinel, RedisCluster, r.Mode)
+	}
+	for _, a := range r.Addrs {
+		_, port, err := net.SplitHostPort(a)
+		check(err == nil && port != "", "addrs", "%q must be host:port", a)
+	}
+	check(r.DB >= 0, "db", "must not be negative")
+
+	check(r.PoolSize >= 0, "pool_size", "must not be negative")
+	check(r.MinIdleConns >= 0, "min_idle_conns", "must not be negative")
+	check(r.PoolSize == 0 || r.MinIdleConns <= r.PoolSize, "min_idle_conns", "must not exceed pool_size")
+	check(r.PoolTimeout >= 0, "pool_timeout", "must not be negative")
+	check(r.DialTimeout >= 0, "dial_timeout", "must not be negative")
+	check(r.ReadTimeout >= 0, "read_timeout", "must not be negative")
+	check(r.WriteTimeout >= 0, "write_timeout", "must not be negative")
+	check(r.MaxRetries >= -1, "max_retries", "must be -1 (disabled) or more")
+
+	t := r.TLS
+	check((t.CertFile == "") == (t.KeyFile == ""), "tls", "cert_file and key_file must be set together")
+	if t.Enabled {
+		files := []struct{ path, file string }{
+			{"tls.ca_file", t.CAFile}, {"tls.cert_file", t.CertFile}, {"tls.key_file", t.KeyFile},
+		}
+		for _, f := range files {
+			if f.file != "" {
+				_, err := os.Stat(f.file)
+				check(err == nil, f.path, "%v", err)
+			}
+		}
+	}
+	return errs
+}
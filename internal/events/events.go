@@ -0,0 +1,151 @@
+// Package events publishes leaderboard events to Redis for other services.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// TypeTierChanged is emitted when a submission moves the submitting
+	// player into a different tier. Players whose percentile tier changes
+	// as a side effect of someone else's submission are not reported.
+	TypeTierChanged = "tier.changed"
+
+	// Channel is the default Pub/Sub channel events are published on.
+	Channel = "events:leaderboard"
+	// Stream is the default stream keeping a capped history of events for
+	// consumers that were not subscribed when they happened.
+	Stream = "events:leaderboard:log"
+
+	streamMaxLen = 10000
+)
+
+type Event struct {
+	Type   string    `json:"type"`
+	Board  string    `json:"board"`
+	Player string    `json:"player"`
+	From   string    `json:"from,omitempty"`
+	To     string    `json:"to,omitempty"`
+	Score  float64   `json:"score"`
+	Time   time.Time `json:"time"`
+}
+
+// Publisher accepts events without blocking the caller.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// RedisPublisher delivers events from a buffered queue on a background
+// goroutine. Events are dropped, with a log line, when the queue is full.
+type RedisPublisher struct {
+	rdb    redis.UniversalClient
+	config PublisherConfig
+	queue  chan Event
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+type PublisherConfig struct {
+	Channel string
+	Stream  string
+	// Buffer bounds the events waiting for delivery.
+	Buffer int
+}
+
+func NewRedisPublisher(rdb redis.UniversalClient, config PublisherConfig) *RedisPublisher {
+	if config.Channel == "" {
+		config.Channel = Channel
+	}
+	if config.Stream == "" {
+		config.Stream = Stream
+	}
+	p := &RedisPublisher{
+		rdb:    rdb,
+		config: config,
+		queue:  make(chan Event, config.Buffer),
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+func (p *RedisPublisher) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	select {
+	case p.queue <- e:
+	default:
+		log.Printf("[events] queue full, dropping %s for %s", e.Type, e.Player)
+	}
+}
+
+// Close stops accepting events and waits until queued ones are delivered or
+// ctx is done. Publish must not be called after Close.
+func (p *RedisPublisher) Close(ctx context.Context) error {
+	p.once.Do(func() { close(p.queue) })
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *RedisPublisher) run() {
+	defer p.wg.Done()
+	for e := range p.queue {
+		if err := p.deliver(e); err != nil {
+			log.Printf("[events] failed to publish %s for %s: %v", e.Type, e.Player, err)
+		}
+	}
+}
+
+func (p *RedisPublisher) deliver(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipe := p.rdb.Pipeline()
+	pipe.Publish(ctx, p.config.Channel, payload)
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.config.Stream,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"event": payload},
+	})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// LogPublisher writes events to the log. It stands in for RedisPublisher
+// when the service runs without Redis.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[events] failed to encode %s for %s: %v", e.Type, e.Player, err)
+		return
+	}
+	log.Printf("[events] %s", payload)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"go-redis/internal/bans"
+	"go-redis/internal/clientip"
+	"go-redis/internal/config"
+	"go-redis/internal/problem"
+	"log/slog"
+	"net/http"
+	"net/netip"
+)
+
+type AdminHandler struct {
+	config *config.Config
+	bans   bans.Store
+}
+
+func NewAdminHandler(cfg *config.Config, bans bans.Store) *AdminHandler {
+	return &AdminHandler{config: cfg, bans: bans}
+}
+
+// Config handles GET /admin/config
+// Returns the effective configuration with secrets redacted.
+func (h *AdminHandler) Config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.config.Redacted())
+}
+
+// Bans handles GET /admin/bans
+// Returns every ban in force.
+func (h *AdminHandler) Bans(w http.ResponseWriter, r *http.Request) {
+	list, err := h.bans.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list bans", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Ban handles GET /admin/bans/{client}
+func (h *AdminHandler) Ban(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.client(w, r)
+	if !ok {
+		return
+	}
+	ban, err := h.bans.Get(r.Context(), client)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get ban", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	if ban == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeBanNotFound, client+" is not banned")
+		return
+	}
+	writeJSON(w, http.StatusOK, ban)
+}
+
+// LiftBan handles DELETE /admin/bans/{client}
+// Returns the ban that was lifted.
+func (h *AdminHandler) LiftBan(w http.ResponseWriter, r *http.Request) {
+	client, ok := h.client(w, r)
+	if !ok {
+		return
+	}
+	ban, err := h.bans.Get(r.Context(), client)
+	if err == nil && ban != nil {
+		_, err = h.bans.Lift(r.Context(), client)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to lift ban", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	if ban == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeBanNotFound, client+" is not banned")
+		return
+	}
+	slog.InfoContext(r.Context(), "Ban lifted", "client", client)
+	writeJSON(w, http.StatusOK, ban)
+}
+
+// client reads the {client} path value: an address, grouped as the rate
+// limiter groups it, or a network as listed by Bans.
+func (h *AdminHandler) client(w http.ResponseWriter, r *http.Request) (string, bool) {
+	value := r.PathValue("client")
+	if addr, err := netip.ParseAddr(value); err == nil {
+		return clientip.Group(addr.Unmap(), h.config.ClientIP.IPv6Prefix), true
+	}
+	if p, err := netip.ParsePrefix(value); err == nil {
+		return p.Masked().String(), true
+	}
+	problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "client must be an IP address or network")
+	return "", false
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-redis/internal/health"
+	"net/http"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live handles GET /livez
+// Answers as long as the process can serve requests; dependencies are not
+// consulted, so a Redis outage does not get the process restarted.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// Ready handles GET /readyz
+// Returns the detailed health report, with 503 when the service should not
+// receive traffic.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Report(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(readyStatus(report))
+	json.NewEncoder(w).Encode(report)
+}
+
+// Health handles GET /health
+// The plain-text form of Ready kept for existing probes.
+func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Report(r.Context())
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(readyStatus(report))
+	switch {
+	case report.Draining:
+		w.Write([]byte("draining"))
+	case !report.Ready():
+		w.Write([]byte("unavailable"))
+	default:
+		w.Write([]byte("OK"))
+	}
+}
+
+func readyStatus(report health.Report) int {
+	if report.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
@@ -1,215 +1,324 @@
 package handlers
 
 import (
-    "encoding/json"
-    "go-redis/internal/models"
-    "net/http"
-    "strconv"
-
-    "github.com/redis/go-redis/v9"
+	"context"
+	"encoding/json"
+	"errors"
+	"go-redis/internal/models"
+	"go-redis/internal/store"
+	"go-redis/internal/tiers"
+	"net/http"
 )
 
-const leaderboardSet = "scores"
+var errPlayerNotFound = errors.New("player not found")
+
+// Limits bounds the size of leaderboard queries.
+type Limits struct {
+	DefaultLimit  int
+	MaxLimit      int
+	DefaultRadius int
+	MaxRadius     int
+}
 
 type LeaderboardHandler struct {
-    redisClient *redis.Client
+	store  store.LeaderboardStore
+	tiers  *tiers.Definition
+	limits Limits
 }
 
-func NewLeaderboardHandler(redisClient *redis.Client) *LeaderboardHandler {
-    return &LeaderboardHandler{redisClient: redisClient}
+func NewLeaderboardHandler(lb store.LeaderboardStore, tierSet tiers.Set, limits Limits) *LeaderboardHandler {
+	return &LeaderboardHandler{
+		store:  lb,
+		tiers:  tierSet.For(lb.Board()),
+		limits: limits,
+	}
 }
 
 // Top handles GET /leaderboard/top?limit=10
 func (h *LeaderboardHandler) Top(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "application/json")
-
-    if r.Method != http.MethodGet {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-        return
-    }
-
-    limit := 10
-    if v := r.URL.Query().Get("limit"); v != "" {
-        if n, err := strconv.Atoi(v); err == nil {
-            limit = n
-        }
-    }
-    if limit <= 0 {
-        limit = 10
-    }
-    if limit > 100 {
-        limit = 100
-    }
-
-    ctx := r.Context()
-    stop := int64(limit - 1)
-    zs, err := h.redisClient.ZRevRangeWithScores(ctx, leaderboardSet, 0, stop).Result()
-    if err != nil {
-        http.Error(w, "Internal server error", http.StatusInternalServerError)
-        return
-    }
-
-    entries := make([]models.LeaderboardEntry, 0, len(zs))
-    for i, z := range zs {
-        memberStr, _ := z.Member.(string)
-        entries = append(entries, models.LeaderboardEntry{
-            Rank:   i + 1,
-            Player: memberStr,
-            Score:  z.Score,
-        })
-    }
-
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "status":  "success",
-        "message": "Top players retrieved successfully",
-        "limit":   limit,
-        "data":    entries,
-    })
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, err := queryInt(r, "limit", h.limits.DefaultLimit)
+	if err != nil {
+		limit = h.limits.DefaultLimit
+	}
+	limit = h.clampLimit(limit)
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		mode = rankOrdinal
+	}
+
+	entries, err := h.topEntries(r.Context(), limit, mode)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Top players retrieved successfully",
+		"limit":   limit,
+		"data":    entries,
+	})
 }
 
 // Player handles GET /leaderboard/player?player=:id
 // Returns rank (1-based), score, and percentile (0-100 where higher is better)
 func (h *LeaderboardHandler) Player(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "application/json")
-
-    if r.Method != http.MethodGet {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-        return
-    }
-
-    player := r.URL.Query().Get("player")
-    if player == "" {
-        http.Error(w, "Player is required", http.StatusBadRequest)
-        return
-    }
-
-    ctx := r.Context()
-
-    rank0, err := h.redisClient.ZRevRank(ctx, leaderboardSet, player).Result()
-    if err != nil {
-        if err == redis.Nil {
-            w.WriteHeader(http.StatusOK)
-            json.NewEncoder(w).Encode(map[string]interface{}{
-                "status":  "success",
-                "message": "Player not found",
-                "player":  player,
-                "rank":    nil,
-                "score":   0,
-                "percentile": 0,
-            })
-            return
-        }
-        http.Error(w, "Internal server error", http.StatusInternalServerError)
-        return
-    }
-
-    pipe := h.redisClient.Pipeline()
-    scoreCmd := pipe.ZScore(ctx, leaderboardSet, player)
-    totalCmd := pipe.ZCard(ctx, leaderboardSet)
-    if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
-        http.Error(w, "Internal server error", http.StatusInternalServerError)
-        return
-    }
-
-    score, _ := scoreCmd.Result()
-    total, _ := totalCmd.Result()
-
-    rank := int(rank0) + 1
-    var percentile float64
-    if total > 0 {
-        percentile = (1 - (float64(rank) / float64(total))) * 100.0
-        if percentile < 0 {
-            percentile = 0
-        }
-    }
-
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "status":     "success",
-        "message":    "Player rank retrieved successfully",
-        "player":     player,
-        "rank":       rank,
-        "score":      score,
-        "total":      total,
-        "percentile": percentile,
-    })
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	player := r.URL.Query().Get("player")
+	if player == "" {
+		http.Error(w, "Player is required", http.StatusBadRequest)
+		return
+	}
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		mode = rankOrdinal
+	}
+
+	rank, err := h.playerRank(r.Context(), player, mode)
+	if err != nil {
+		if err == errPlayerNotFound {
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":     "success",
+				"message":    "Player not found",
+				"player":     player,
+				"rank":       nil,
+				"score":      0,
+				"percentile": 0,
+			})
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"message":    "Player rank retrieved successfully",
+		"player":     player,
+		"rank":       rank.Rank,
+		"score":      rank.Score,
+		"total":      rank.Total,
+		"percentile": rank.Percentile,
+		"tier":       rank.Tier,
+	})
 }
 
 // Around handles GET /leaderboard/around/{player}?radius=2
 // Returns entries around the player's current rank.
 func (h *LeaderboardHandler) Around(w http.ResponseWriter, r *http.Request) {
-    w.Header().Set("Content-Type", "application/json")
-
-    if r.Method != http.MethodGet {
-        http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-        return
-    }
-
-    player := r.PathValue("player")
-    if player == "" {
-        http.Error(w, "Player is required", http.StatusBadRequest)
-        return
-    }
-
-    radius := 2
-    if v := r.URL.Query().Get("radius"); v != "" {
-        if n, err := strconv.Atoi(v); err == nil {
-            radius = n
-        }
-    }
-    if radius < 1 {
-        radius = 1
-    }
-    if radius > 10 {
-        radius = 10
-    }
-
-    ctx := r.Context()
-    rank0, err := h.redisClient.ZRevRank(ctx, leaderboardSet, player).Result()
-    if err != nil {
-        if err == redis.Nil {
-            w.WriteHeader(http.StatusOK)
-            json.NewEncoder(w).Encode(map[string]interface{}{
-                "status":  "success",
-                "message": "Player not found",
-                "player":  player,
-                "data":    []models.LeaderboardEntry{},
-            })
-            return
-        }
-        http.Error(w, "Internal server error", http.StatusInternalServerError)
-        return
-    }
-
-    start := int64(0)
-    if int64(rank0)-int64(radius) > 0 {
-        start = int64(rank0) - int64(radius)
-    }
-    end := int64(rank0) + int64(radius)
-
-    zs, err := h.redisClient.ZRevRangeWithScores(ctx, leaderboardSet, start, end).Result()
-    if err != nil {
-        http.Error(w, "Internal server error", http.StatusInternalServerError)
-        return
-    }
-
-    entries := make([]models.LeaderboardEntry, 0, len(zs))
-    for i, z := range zs {
-        memberStr, _ := z.Member.(string)
-        entries = append(entries, models.LeaderboardEntry{
-            Rank:   int(start) + i + 1,
-            Player: memberStr,
-            Score:  z.Score,
-        })
-    }
-
-    w.WriteHeader(http.StatusOK)
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "status":  "success",
-        "message": "Around player window retrieved successfully",
-        "player":  player,
-        "radius":  radius,
-        "data":    entries,
-    })
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	player := r.PathValue("player")
+	if player == "" {
+		http.Error(w, "Player is required", http.StatusBadRequest)
+		return
+	}
+
+	radius, err := queryInt(r, "radius", h.limits.DefaultRadius)
+	if err != nil {
+		radius = h.limits.DefaultRadius
+	}
+	radius = h.clampRadius(radius)
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		mode = rankOrdinal
+	}
+
+	entries, err := h.aroundEntries(r.Context(), player, radius, mode)
+	if err != nil {
+		if err == errPlayerNotFound {
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  "success",
+				"message": "Player not found",
+				"player":  player,
+				"data":    []models.LeaderboardEntry{},
+			})
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Around player window retrieved successfully",
+		"player":  player,
+		"radius":  radius,
+		"data":    entries,
+	})
+}
+
+// clampLimit keeps limit within 1..MaxLimit; non-positive values fall back
+// to DefaultLimit.
+func (h *LeaderboardHandler) clampLimit(limit int) int {
+	if limit <= 0 {
+		return h.limits.DefaultLimit
+	}
+	if limit > h.limits.MaxLimit {
+		return h.limits.MaxLimit
+	}
+	return limit
+}
+
+// clampRadius keeps radius within 1..MaxRadius.
+func (h *LeaderboardHandler) clampRadius(radius int) int {
+	if radius < 1 {
+		return 1
+	}
+	if radius > h.limits.MaxRadius {
+		return h.limits.MaxRadius
+	}
+	return radius
+}
+
+func (h *LeaderboardHandler) topEntries(ctx context.Context, limit int, mode rankMode) ([]models.LeaderboardEntry, error) {
+	stop := int64(limit - 1)
+	members, err := h.store.RangeByRank(ctx, 0, stop)
+	if err != nil {
+		return nil, err
+	}
+	entries := toEntries(members, 0)
+	if err := assignRanks(ctx, h.store, entries, mode); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// playerRank returns errPlayerNotFound when player has no score.
+func (h *LeaderboardHandler) playerRank(ctx context.Context, player string, mode rankMode) (models.PlayerRankResponse, error) {
+	score, err := h.store.Score(ctx, player)
+	if err == store.ErrNotFound {
+		return models.PlayerRankResponse{}, errPlayerNotFound
+	}
+	if err != nil {
+		return models.PlayerRankResponse{}, err
+	}
+	ordinal, err := h.store.Rank(ctx, player)
+	if err == store.ErrNotFound {
+		return models.PlayerRankResponse{}, errPlayerNotFound
+	}
+	if err != nil {
+		return models.PlayerRankResponse{}, err
+	}
+	total, err := h.store.Card(ctx)
+	if err != nil {
+		return models.PlayerRankResponse{}, err
+	}
+	below, err := h.store.Count(ctx, store.Below(score))
+	if err != nil {
+		return models.PlayerRankResponse{}, err
+	}
+	rank, err := rankOf(ctx, h.store, mode, score, ordinal)
+	if err != nil {
+		return models.PlayerRankResponse{}, err
+	}
+	percentile := percentileOf(below, total)
+
+	return models.PlayerRankResponse{
+		Player:     player,
+		Rank:       rank,
+		RankMode:   string(mode),
+		Score:      score,
+		Total:      total,
+		Percentile: percentile,
+		Tier:       h.tiers.Classify(score, percentile),
+	}, nil
+}
+
+// aroundEntries returns errPlayerNotFound when player has no score.
+func (h *LeaderboardHandler) aroundEntries(ctx context.Context, player string, radius int, mode rankMode) ([]models.LeaderboardEntry, error) {
+	rank0, err := h.store.Rank(ctx, player)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, errPlayerNotFound
+		}
+		return nil, err
+	}
+
+	start := int64(0)
+	if int64(rank0)-int64(radius) > 0 {
+		start = int64(rank0) - int64(radius)
+	}
+	end := int64(rank0) + int64(radius)
+
+	members, err := h.store.RangeByRank(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := toEntries(members, start)
+	if err := assignRanks(ctx, h.store, entries, mode); err != nil {
+		return nil, err
+	}
+	if err := h.classify(ctx, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// classify sets the tier of each entry.
+func (h *LeaderboardHandler) classify(ctx context.Context, entries []models.LeaderboardEntry) error {
+	if h.tiers == nil || len(entries) == 0 {
+		return nil
+	}
+	if h.tiers.Mode == tiers.ByScore {
+		for i := range entries {
+			entries[i].Tier = h.tiers.Classify(entries[i].Score, 0)
+		}
+		return nil
+	}
+
+	total, err := h.store.Card(ctx)
+	if err != nil {
+		return err
+	}
+	below, err := belowCounts(ctx, h.store, entries)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		e := &entries[i]
+		e.Tier = h.tiers.Classify(e.Score, percentileOf(below[e.Score], total))
+	}
+	return nil
+}
+
+// toEntries converts a descending run of members starting at zero-based
+// position start.
+func toEntries(members []store.Member, start int64) []models.LeaderboardEntry {
+	entries := make([]models.LeaderboardEntry, 0, len(members))
+	for i, m := range members {
+		entries = append(entries, models.LeaderboardEntry{
+			Rank:   int(start) + i + 1,
+			Player: m.Player,
+			Score:  m.Score,
+		})
+	}
+	return entries
 }
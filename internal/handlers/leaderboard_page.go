@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"go-redis/internal/store"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageCursor marks a position by the sort key of an entry (score, then
+// player) rather than by its rank, so following a cursor neither repeats nor
+// skips entries when players above it gain or lose points.
+type pageCursor struct {
+	Score  float64 `json:"s"`
+	Player string  `json:"p"`
+	// Ties counts the players tied with the anchor that sorted before it
+	// when the cursor was issued; it places the anchor among its ties
+	// should it leave the board.
+	Ties int64 `json:"t,omitempty"`
+	// Before selects the page preceding the anchor instead of following it.
+	Before bool `json:"b,omitempty"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// Browse handles GET /v2/leaderboard?cursor=&limit=10 and
+// GET /v2/leaderboard?offset=&limit=10. cursor and offset are exclusive;
+// without either the first page is returned. It is only served under /v2:
+// cursor errors need problem+json, and /v1 clients page with top.
+func (h *LeaderboardHandler) Browse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", h.limits.DefaultLimit)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "limit must be an integer")
+		return
+	}
+	limit = h.clampLimit(limit)
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("cursor") != "" && q.Get("offset") != "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "cursor and offset cannot be combined")
+		return
+	}
+
+	ctx := r.Context()
+	start, count := int64(0), int64(limit)
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidCursor, "cursor is malformed")
+			return
+		}
+		before, found, err := h.cursorPosition(ctx, c)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to resolve leaderboard cursor", "err", err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+			return
+		}
+		switch {
+		case c.Before:
+			start = max(0, before-int64(limit))
+			count = before - start
+		case found:
+			start = before + 1
+		default:
+			start = before
+		}
+	} else {
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil || offset < 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "offset must be a non-negative integer")
+			return
+		}
+		start = int64(offset)
+	}
+
+	page, err := h.page(ctx, start, count, mode)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get leaderboard page", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	page.Limit = limit
+
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, pageLink(r.URL, page.NextCursor, limit, "next"))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, pageLink(r.URL, page.PrevCursor, limit, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// page returns count entries starting at the zero-based position start,
+// with cursors for the neighbouring pages.
+func (h *LeaderboardHandler) page(ctx context.Context, start, count int64, mode rankMode) (models.PageResponse, error) {
+	page := models.PageResponse{Offset: start, RankMode: string(mode), Entries: []models.LeaderboardEntry{}}
+
+	total, err := h.store.Card(ctx)
+	if err != nil {
+		return page, err
+	}
+	page.Total = total
+	if count > 0 {
+		members, err := h.store.RangeByRank(ctx, start, start+count-1)
+		if err != nil {
+			return page, err
+		}
+		page.Entries = toEntries(members, start)
+	}
+	if err := assignRanks(ctx, h.store, page.Entries, mode); err != nil {
+		return page, err
+	}
+
+	if n := len(page.Entries); n > 0 {
+		if start+int64(n) < page.Total {
+			c, err := h.cursorAt(ctx, page.Entries[n-1], start+int64(n)-1)
+			if err != nil {
+				return page, err
+			}
+			page.NextCursor = encodeCursor(c)
+		}
+		if start > 0 {
+			c, err := h.cursorAt(ctx, page.Entries[0], start)
+			if err != nil {
+				return page, err
+			}
+			c.Before = true
+			page.PrevCursor = encodeCursor(c)
+		}
+	}
+	return page, nil
+}
+
+// cursorAt anchors a cursor on e, found at the zero-based position pos.
+func (h *LeaderboardHandler) cursorAt(ctx context.Context, e models.LeaderboardEntry, pos int64) (pageCursor, error) {
+	above, err := h.store.Count(ctx, store.Above(e.Score))
+	if err != nil {
+		return pageCursor{}, err
+	}
+	return pageCursor{Score: e.Score, Player: e.Player, Ties: max(0, pos-above)}, nil
+}
+
+// cursorPosition returns how many entries sort before the cursor's anchor
+// and whether the anchor is still on the board with the same score. When it
+// has moved or left, before is where it would sort, so pages continue from
+// the same place in the ordering.
+func (h *LeaderboardHandler) cursorPosition(ctx context.Context, c pageCursor) (before int64, found bool, err error) {
+	score, err := h.store.Score(ctx, c.Player)
+	if err != nil && err != store.ErrNotFound {
+		return 0, false, err
+	}
+	if err == nil && score == c.Score {
+		rank, err := h.store.Rank(ctx, c.Player)
+		if err == nil {
+			return rank, true, nil
+		}
+		if err != store.ErrNotFound {
+			return 0, false, err
+		}
+	}
+
+	// The anchor goes after the players now above its score and, among
+	// those still tied at it, after as many as preceded it before.
+	before, err = h.store.Count(ctx, store.Above(c.Score))
+	if err != nil {
+		return 0, false, err
+	}
+	ties, err := h.store.Count(ctx, store.Between(c.Score, c.Score))
+	if err != nil {
+		return 0, false, err
+	}
+	return before + min(max(c.Ties, 0), ties), false, nil
+}
+
+func pageLink(u *url.URL, cursor string, limit int, rel string) string {
+	q := u.Query()
+	q.Del("offset")
+	q.Set("cursor", cursor)
+	q.Set("limit", strconv.Itoa(limit))
+	return "<" + u.Path + "?" + q.Encode() + `>; rel="` + rel + `"`
+}
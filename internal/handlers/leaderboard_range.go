@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"go-redis/internal/store"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ScoreRange handles GET /v2/leaderboard/scores?min=1000&max=2000&offset=0&limit=10
+// Bounds are inclusive and default to unbounded.
+func (h *LeaderboardHandler) ScoreRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	minScore, err := queryFloat(r, "min", math.Inf(-1))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "min must be a number")
+		return
+	}
+	maxScore, err := queryFloat(r, "max", math.Inf(1))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "max must be a number")
+		return
+	}
+	if minScore > maxScore {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "min must not exceed max")
+		return
+	}
+
+	offset, limit, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	resp, err := h.scoreRange(r.Context(), minScore, maxScore, offset, limit, mode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get score range", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	setOffsetLinks(w, r.URL, offset, limit, resp.Count)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// RankRange handles GET /v2/leaderboard/ranks?from=500&to=600&offset=0&limit=10
+// from is clamped to at least 1 and to is capped at the number of players.
+func (h *LeaderboardHandler) RankRange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	from, err := queryInt(r, "from", 1)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "from must be an integer")
+		return
+	}
+	if from < 1 {
+		from = 1
+	}
+	to, err := queryInt(r, "to", math.MaxInt32)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "to must be an integer")
+		return
+	}
+	if to < from {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "to must not be less than from")
+		return
+	}
+
+	offset, limit, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	resp, err := h.rankRange(r.Context(), int64(from), int64(to), offset, limit, mode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get rank range", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	setOffsetLinks(w, r.URL, offset, limit, resp.Count)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *LeaderboardHandler) scoreRange(ctx context.Context, minScore, maxScore float64, offset int64, limit int, mode rankMode) (models.ScoreRangeResponse, error) {
+	resp := models.ScoreRangeResponse{Offset: offset, Limit: limit, RankMode: string(mode)}
+	if !math.IsInf(minScore, 0) {
+		resp.Min = &minScore
+	}
+	if !math.IsInf(maxScore, 0) {
+		resp.Max = &maxScore
+	}
+
+	count, err := h.store.Count(ctx, store.Between(minScore, maxScore))
+	if err != nil {
+		return resp, err
+	}
+	// Everyone scoring above max is ranked ahead of the range.
+	above, err := h.store.Count(ctx, store.Above(maxScore))
+	if err != nil {
+		return resp, err
+	}
+	members, err := h.store.RangeByScore(ctx, store.Between(minScore, maxScore), offset, int64(limit))
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Count = count
+	resp.Entries = toEntries(members, above+offset)
+	return resp, assignRanks(ctx, h.store, resp.Entries, mode)
+}
+
+// rankRange selects players by ordinal position; mode only affects the
+// ranks reported for them.
+func (h *LeaderboardHandler) rankRange(ctx context.Context, from, to, offset int64, limit int, mode rankMode) (models.RankRangeResponse, error) {
+	resp := models.RankRangeResponse{From: from, Offset: offset, Limit: limit, RankMode: string(mode), Entries: []models.LeaderboardEntry{}}
+
+	total, err := h.store.Card(ctx)
+	if err != nil {
+		return resp, err
+	}
+	resp.To = min(to, total)
+	if resp.To < from {
+		return resp, nil
+	}
+	resp.Count = resp.To - from + 1
+	if offset >= resp.Count {
+		return resp, nil
+	}
+
+	start := from - 1 + offset
+	stop := min(start+int64(limit), resp.To) - 1
+	members, err := h.store.RangeByRank(ctx, start, stop)
+	if err != nil {
+		return resp, err
+	}
+	resp.Entries = toEntries(members, start)
+	return resp, assignRanks(ctx, h.store, resp.Entries, mode)
+}
+
+// pageParams reads offset and limit for range queries, writing a problem and
+// returning ok == false when either is invalid.
+func (h *LeaderboardHandler) pageParams(w http.ResponseWriter, r *http.Request) (offset int64, limit int, ok bool) {
+	limit, err := queryInt(r, "limit", h.limits.DefaultLimit)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "limit must be an integer")
+		return 0, 0, false
+	}
+	off, err := queryInt(r, "offset", 0)
+	if err != nil || off < 0 {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "offset must be a non-negative integer")
+		return 0, 0, false
+	}
+	return int64(off), h.clampLimit(limit), true
+}
+
+// setOffsetLinks sets next/prev Link headers for offset-paged results.
+func setOffsetLinks(w http.ResponseWriter, u *url.URL, offset int64, limit int, count int64) {
+	link := func(off int64, rel string) string {
+		q := u.Query()
+		q.Set("offset", strconv.FormatInt(off, 10))
+		q.Set("limit", strconv.Itoa(limit))
+		return "<" + u.Path + "?" + q.Encode() + `>; rel="` + rel + `"`
+	}
+
+	var links []string
+	if next := offset + int64(limit); next < count {
+		links = append(links, link(next, "next"))
+	}
+	if offset > 0 {
+		links = append(links, link(max(0, offset-int64(limit)), "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// queryFloat parses the named query parameter, returning def when it is
+// absent. "-inf" and "+inf" are accepted.
+func queryFloat(r *http.Request, name string, def float64) (float64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, strconv.ErrSyntax
+	}
+	return f, nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"go-redis/internal/store"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// meanSampleSize bounds how many players are read to estimate the mean when
+// the running totals are unavailable.
+const meanSampleSize = 1000
+
+var defaultPercentiles = []float64{25, 50, 75, 90, 95, 99}
+
+// Stats handles GET /v2/leaderboard/stats?percentiles=50,90,99&buckets=10
+// Every figure except a sampled mean costs O(log n) per value, so the
+// endpoint stays cheap on very large boards.
+func (h *LeaderboardHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	percentiles := defaultPercentiles
+	if v := r.URL.Query().Get("percentiles"); v != "" {
+		percentiles = nil
+		for _, part := range strings.Split(v, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || p < 0 || p > 100 {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "percentiles must be numbers within 0-100")
+				return
+			}
+			percentiles = append(percentiles, p)
+		}
+	}
+
+	buckets, err := queryInt(r, "buckets", 10)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "buckets must be an integer")
+		return
+	}
+	buckets = max(1, min(buckets, 50))
+
+	stats, err := h.stats(r.Context(), percentiles, buckets)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to compute leaderboard stats", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (h *LeaderboardHandler) stats(ctx context.Context, percentiles []float64, buckets int) (models.StatsResponse, error) {
+	resp := models.StatsResponse{
+		Percentiles: map[string]float64{},
+		Histogram:   []models.HistogramBucket{},
+	}
+
+	n, err := h.store.Card(ctx)
+	if err != nil {
+		return resp, err
+	}
+	resp.Count = n
+	if n == 0 {
+		return resp, nil
+	}
+
+	// Order statistics are defined on ascending index i, which is position
+	// n-1-i in the store's descending order.
+	at := func(i int64) (float64, error) {
+		members, err := h.store.RangeByRank(ctx, n-1-i, n-1-i)
+		if err != nil || len(members) == 0 {
+			return 0, err
+		}
+		return members[0].Score, nil
+	}
+	if resp.Min, err = at(0); err != nil {
+		return resp, err
+	}
+	if resp.Max, err = at(n - 1); err != nil {
+		return resp, err
+	}
+
+	lower, err := at((n - 1) / 2)
+	if err != nil {
+		return resp, err
+	}
+	upper, err := at(n / 2)
+	if err != nil {
+		return resp, err
+	}
+	resp.Median = (lower + upper) / 2
+
+	for _, p := range percentiles {
+		idx := int64(math.Ceil(p/100*float64(n))) - 1
+		idx = max(0, min(idx, n-1))
+		v, err := at(idx)
+		if err != nil {
+			return resp, err
+		}
+		resp.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = v
+	}
+
+	width := (resp.Max - resp.Min) / float64(buckets)
+	if width == 0 {
+		buckets = 1
+	}
+	for i := 0; i < buckets; i++ {
+		b := models.HistogramBucket{
+			Min: resp.Min + float64(i)*width,
+			Max: resp.Min + float64(i+1)*width,
+		}
+		r := store.ScoreRange{Min: store.Bound{Value: b.Min}, Max: store.Bound{Value: b.Max, Exclusive: true}}
+		if i == buckets-1 {
+			b.Max = resp.Max
+			r.Max = store.Bound{Value: b.Max}
+		}
+		if b.Count, err = h.store.Count(ctx, r); err != nil {
+			return resp, err
+		}
+		resp.Histogram = append(resp.Histogram, b)
+	}
+
+	mean, sampled, size, err := h.mean(ctx, n)
+	if err != nil {
+		return resp, err
+	}
+	resp.Mean, resp.MeanSampled, resp.SampleSize = mean, sampled, size
+	return resp, nil
+}
+
+// mean uses the store's running sum when it is exact. Otherwise it reads
+// the whole board when small, or estimates from a random sample.
+func (h *LeaderboardHandler) mean(ctx context.Context, n int64) (mean float64, sampled bool, size int, err error) {
+	sum, exact, err := h.store.Sum(ctx)
+	if err != nil {
+		return 0, false, 0, err
+	}
+	if exact {
+		return sum / float64(n), false, 0, nil
+	}
+
+	var members []store.Member
+	if n <= meanSampleSize {
+		members, err = h.store.RangeByRank(ctx, 0, -1)
+	} else {
+		sampled = true
+		members, err = h.store.Sample(ctx, meanSampleSize)
+	}
+	if err != nil || len(members) == 0 {
+		return 0, sampled, 0, err
+	}
+	sum = 0
+	for _, m := range members {
+		sum += m.Score
+	}
+	if sampled {
+		size = len(members)
+	}
+	return sum / float64(len(members)), sampled, size, nil
+}
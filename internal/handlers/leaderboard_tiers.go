@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"go-redis/internal/store"
+	"go-redis/internal/tiers"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// Tiers handles GET /v2/leaderboard/tiers
+// Lists the configured tiers with their bounds and current member counts.
+func (h *LeaderboardHandler) Tiers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	resp := models.TiersResponse{Tiers: []models.TierInfo{}}
+	if h.tiers == nil {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.Mode = string(h.tiers.Mode)
+
+	ctx := r.Context()
+	for _, t := range h.tiers.Tiers {
+		lo, hi, _ := h.tiers.Bounds(t.Name)
+		info := models.TierInfo{Name: t.Name, Min: lo}
+		if !math.IsInf(hi, 1) {
+			info.Max = &hi
+		}
+
+		bounds, err := h.tierScoreBounds(ctx, lo, hi)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to resolve tier bounds", "err", err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+			return
+		}
+		if info.Count, err = h.store.Count(ctx, bounds); err != nil {
+			slog.ErrorContext(ctx, "Failed to count tier members", "err", err)
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+			return
+		}
+		resp.Tiers = append(resp.Tiers, info)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// TierMembers handles GET /v2/leaderboard/tiers/{tier}?offset=0&limit=10
+func (h *LeaderboardHandler) TierMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	name := r.PathValue("tier")
+	lo, hi, ok := h.tiers.Bounds(name)
+	if !ok {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeTierNotFound, "no tier named "+name)
+		return
+	}
+
+	offset, limit, ok := h.pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	resp, err := h.tierMembers(r.Context(), lo, hi, offset, limit, mode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get tier members", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+	resp.Tier = name
+	for i := range resp.Entries {
+		resp.Entries[i].Tier = name
+	}
+
+	setOffsetLinks(w, r.URL, offset, limit, resp.Count)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *LeaderboardHandler) tierMembers(ctx context.Context, lo, hi float64, offset int64, limit int, mode rankMode) (models.TierMembersResponse, error) {
+	resp := models.TierMembersResponse{Offset: offset, Limit: limit, RankMode: string(mode)}
+
+	bounds, err := h.tierScoreBounds(ctx, lo, hi)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.Count, err = h.store.Count(ctx, bounds); err != nil {
+		return resp, err
+	}
+	above, err := h.store.Count(ctx, store.ScoreRange{Min: toggleBound(bounds.Max), Max: store.Bound{Value: math.Inf(1)}})
+	if err != nil {
+		return resp, err
+	}
+	members, err := h.store.RangeByScore(ctx, bounds, offset, int64(limit))
+	if err != nil {
+		return resp, err
+	}
+	resp.Entries = toEntries(members, above+offset)
+	return resp, assignRanks(ctx, h.store, resp.Entries, mode)
+}
+
+// tierScoreBounds converts the tier interval [lo, hi) into a score range.
+// Percentile bands are resolved against the current board, so both modes
+// are served by score range queries.
+func (h *LeaderboardHandler) tierScoreBounds(ctx context.Context, lo, hi float64) (store.ScoreRange, error) {
+	bounds := store.ScoreRange{Min: store.Bound{Value: lo}, Max: store.Bound{Value: math.Inf(1)}}
+	if h.tiers.Mode == tiers.ByScore {
+		if !math.IsInf(hi, 1) {
+			bounds.Max = store.Bound{Value: hi, Exclusive: true}
+		}
+		return bounds, nil
+	}
+
+	total, err := h.store.Card(ctx)
+	if err != nil {
+		return bounds, err
+	}
+	if bounds.Min, err = h.percentileFloor(ctx, lo, total); err != nil {
+		return bounds, err
+	}
+	if !math.IsInf(hi, 1) {
+		floor, err := h.percentileFloor(ctx, hi, total)
+		if err != nil {
+			return bounds, err
+		}
+		bounds.Max = toggleBound(floor)
+	}
+	return bounds, nil
+}
+
+// percentileFloor returns the lower score bound selecting exactly the
+// players whose percentile (see percentileOf) is at least p.
+func (h *LeaderboardHandler) percentileFloor(ctx context.Context, p float64, total int64) (store.Bound, error) {
+	none := store.Bound{Value: math.Inf(1), Exclusive: true}
+	if p <= 0 || total <= 1 {
+		return store.Bound{Value: math.Inf(-1)}, nil
+	}
+	// A player qualifies when at least need others score strictly lower. A
+	// small epsilon absorbs float error at exact band edges.
+	need := int64(math.Ceil(p*float64(total-1)/100 - 1e-9))
+	if need >= total {
+		return none, nil
+	}
+
+	// The player at ascending position need has at most need players below;
+	// exactly need unless tied with lower positions, in which case only
+	// strictly higher scores qualify.
+	pos := total - 1 - need
+	members, err := h.store.RangeByRank(ctx, pos, pos)
+	if err != nil || len(members) == 0 {
+		return none, err
+	}
+	s := members[0].Score
+	below, err := h.store.Count(ctx, store.Below(s))
+	if err != nil {
+		return store.Bound{}, err
+	}
+	return store.Bound{Value: s, Exclusive: below < need}, nil
+}
+
+// toggleBound turns an inclusive score bound into an exclusive one and back,
+// converting "score >= x" into "score < x" when used as the other end.
+func toggleBound(b store.Bound) store.Bound {
+	b.Exclusive = !b.Exclusive
+	return b
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+
+	"go-redis/internal/store"
+	"go-redis/internal/tiers"
+)
+
+func TestPercentileFloor(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		scores []float64
+		p      float64
+		want   store.Bound
+	}{
+		{"everyone at 0", []float64{10, 20, 30}, 0, store.Bound{Value: math.Inf(-1)}},
+		{"everyone on a single-player board", []float64{10}, 90, store.Bound{Value: math.Inf(-1)}},
+		{"median", []float64{10, 20, 30, 40, 50}, 50, store.Bound{Value: 30}},
+		{"between positions", []float64{10, 20, 30, 40, 50}, 60, store.Bound{Value: 40}},
+		{"top only", []float64{10, 20, 30, 40, 50}, 100, store.Bound{Value: 50}},
+		// 100/11 * 11/100 rounds above 1.
+		{"exact band edge", []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110, 120}, percentileOf(1, 12), store.Bound{Value: 20}},
+		{"ties below the edge", []float64{10, 20, 20, 20, 50}, 50, store.Bound{Value: 20, Exclusive: true}},
+		{"ties at the edge", []float64{10, 10, 20, 20, 50}, 50, store.Bound{Value: 20}},
+		{"tied top", []float64{10, 50, 50}, 100, store.Bound{Value: 50, Exclusive: true}},
+	}
+	for _, tt := range tests {
+		h, lb := newTierHandler(t, tt.scores)
+		total := int64(len(tt.scores))
+		got, err := h.percentileFloor(ctx, tt.p, total)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: percentileFloor(%v) = %+v, want %+v", tt.name, tt.p, got, tt.want)
+		}
+		// The bound must select exactly the players at or above p.
+		for _, s := range tt.scores {
+			below, err := lb.Count(ctx, store.Below(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			selected := s > got.Value || s == got.Value && !got.Exclusive
+			if want := percentileOf(below, total) >= tt.p; selected != want {
+				t.Errorf("%s: score %v at percentile %v selected: %t", tt.name, s, percentileOf(below, total), selected)
+			}
+		}
+	}
+}
+
+func TestTierScoreBoundsPercentile(t *testing.T) {
+	ctx := context.Background()
+	scores := []float64{10, 20, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	h, lb := newTierHandler(t, scores)
+	total := int64(len(scores))
+	// Adjacent bands must partition the board.
+	edges := []float64{0, 25, 50, 90, math.Inf(1)}
+	seen := 0
+	for i := 0; i+1 < len(edges); i++ {
+		bounds, err := h.tierScoreBounds(ctx, edges[i], edges[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := lb.Count(ctx, bounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen += int(n)
+		for _, s := range scores {
+			below, _ := lb.Count(ctx, store.Below(s))
+			p := percentileOf(below, total)
+			in := p >= edges[i] && p < edges[i+1]
+			inRange := (s > bounds.Min.Value || s == bounds.Min.Value && !bounds.Min.Exclusive) &&
+				(s < bounds.Max.Value || s == bounds.Max.Value && !bounds.Max.Exclusive)
+			if in != inRange {
+				t.Errorf("band [%v, %v): score %v at percentile %v in range %+v: %t", edges[i], edges[i+1], s, p, bounds, inRange)
+			}
+		}
+	}
+	if seen != len(scores) {
+		t.Errorf("the bands hold %d players, want %d", seen, len(scores))
+	}
+}
+
+// newTierHandler serves a percentile-mode board holding scores.
+func newTierHandler(t *testing.T, scores []float64) (*LeaderboardHandler, *store.MemoryStore) {
+	t.Helper()
+	lb := store.NewMemoryStore("test")
+	for i, s := range scores {
+		if _, err := lb.Increment(context.Background(), "p"+strconv.Itoa(i), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set, err := tiers.ParseSet("test=percentile:Bronze=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewLeaderboardHandler(lb, set, Limits{}), lb
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"log/slog"
+	"net/http"
+)
+
+// TopV2 handles GET /v2/leaderboard/top?limit=10
+func (h *LeaderboardHandler) TopV2(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", h.limits.DefaultLimit)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "limit must be an integer")
+		return
+	}
+	limit = h.clampLimit(limit)
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	entries, err := h.topEntries(r.Context(), limit, mode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get top entries", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.TopResponse{
+		Limit:    limit,
+		RankMode: string(mode),
+		Entries:  entries,
+	})
+}
+
+// PlayerV2 handles GET /v2/leaderboard/player?player=:id
+// Unknown players are reported as 404 player_not_found.
+func (h *LeaderboardHandler) PlayerV2(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	player := r.URL.Query().Get("player")
+	if player == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodePlayerRequired, "player query parameter is required")
+		return
+	}
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	rank, err := h.playerRank(r.Context(), player, mode)
+	if err != nil {
+		if err == errPlayerNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.CodePlayerNotFound, "no score recorded for "+player)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to get player rank", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rank)
+}
+
+// AroundV2 handles GET /v2/leaderboard/around/{player}?radius=2
+func (h *LeaderboardHandler) AroundV2(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	player := r.PathValue("player")
+	if player == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodePlayerRequired, "player path segment is required")
+		return
+	}
+
+	radius, err := queryInt(r, "radius", h.limits.DefaultRadius)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "radius must be an integer")
+		return
+	}
+	radius = h.clampRadius(radius)
+
+	mode, err := parseRankMode(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
+		return
+	}
+
+	entries, err := h.aroundEntries(r.Context(), player, radius, mode)
+	if err != nil {
+		if err == errPlayerNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.CodePlayerNotFound, "no score recorded for "+player)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to get around window", "err", err)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.AroundResponse{
+		Player:   player,
+		Radius:   radius,
+		RankMode: string(mode),
+		Entries:  entries,
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"go-redis/internal/models"
+	"go-redis/internal/store"
+	"net/http"
+)
+
+// rankMode selects how tied scores are ranked. For scores 100, 90, 90, 80:
+//
+//	ordinal      1, 2, 3, 4  ties ordered by player, descending (default)
+//	competition  1, 2, 2, 4
+//	dense        1, 2, 2, 3
+type rankMode string
+
+const (
+	rankOrdinal     rankMode = "ordinal"
+	rankCompetition rankMode = "competition"
+	rankDense       rankMode = "dense"
+)
+
+var errInvalidRankMode = errors.New("rank_mode must be ordinal, competition or dense")
+
+func parseRankMode(r *http.Request) (rankMode, error) {
+	switch m := rankMode(r.URL.Query().Get("rank_mode")); m {
+	case "":
+		return rankOrdinal, nil
+	case rankOrdinal, rankCompetition, rankDense:
+		return m, nil
+	}
+	return rankOrdinal, errInvalidRankMode
+}
+
+// percentileOf reports the share of the other players on the board whose
+// score is strictly lower, 0-100. Tied players therefore share a
+// percentile, and the leader of a board is at 100 unless tied. A player
+// alone on a board is at 100.
+func percentileOf(below, total int64) float64 {
+	if total <= 1 {
+		return 100
+	}
+	return 100 * float64(below) / float64(total-1)
+}
+
+// rankOf returns the rank of a player with the given score and zero-based
+// ordinal position under mode.
+func rankOf(ctx context.Context, lb store.LeaderboardStore, mode rankMode, score float64, ordinal int64) (int, error) {
+	switch mode {
+	case rankCompetition:
+		above, err := lb.Count(ctx, store.Above(score))
+		return int(above) + 1, err
+	case rankDense:
+		above, err := lb.DistinctAbove(ctx, score)
+		return int(above) + 1, err
+	}
+	return int(ordinal) + 1, nil
+}
+
+// assignRanks rewrites the ordinal ranks of a contiguous, descending run of
+// entries under mode. Only the first entry needs a lookup; the rest follow
+// from their neighbours.
+func assignRanks(ctx context.Context, lb store.LeaderboardStore, entries []models.LeaderboardEntry, mode rankMode) error {
+	if mode == rankOrdinal || len(entries) == 0 {
+		return nil
+	}
+	first, err := rankOf(ctx, lb, mode, entries[0].Score, int64(entries[0].Rank-1))
+	if err != nil {
+		return err
+	}
+	entries[0].Rank = first
+	for i := 1; i < len(entries); i++ {
+		switch {
+		case entries[i].Score == entries[i-1].Score:
+			entries[i].Rank = entries[i-1].Rank
+		case mode == rankDense:
+			entries[i].Rank = entries[i-1].Rank + 1
+		}
+		// In competition mode an entry that is not tied with its predecessor
+		// keeps its ordinal rank: everyone before it scored strictly higher.
+	}
+	return nil
+}
+
+// belowCounts returns, for each distinct score among entries, how many
+// players scored strictly lower.
+func belowCounts(ctx context.Context, lb store.LeaderboardStore, entries []models.LeaderboardEntry) (map[float64]int64, error) {
+	below := map[float64]int64{}
+	for _, e := range entries {
+		if _, ok := below[e.Score]; ok {
+			continue
+		}
+		n, err := lb.Count(ctx, store.Below(e.Score))
+		if err != nil {
+			return nil, err
+		}
+		below[e.Score] = n
+	}
+	return below, nil
+}
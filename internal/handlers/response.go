@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-redis/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// writeJSON sends data wrapped in the v2 response envelope.
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.Envelope{
+		APIVersion: models.APIVersion,
+		Data:       data,
+	})
+}
+
+// queryInt parses the named query parameter, returning def when it is absent.
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -1,26 +1,35 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"go-redis/internal/events"
+	"go-redis/internal/keys"
+	"go-redis/internal/metrics"
 	"go-redis/internal/models"
-	"log"
+	"go-redis/internal/store"
+	"go-redis/internal/tiers"
+	"log/slog"
 	"net/http"
 	"time"
-
-	"github.com/redis/go-redis/v9"
-)
-
-const (
-	scoreSet = "scores"
 )
 
 type ScoreHandler struct {
-	redisClient *redis.Client
+	store  store.LeaderboardStore
+	kv     store.KV
+	tiers  *tiers.Definition
+	events events.Publisher
+	// idemTTL is how long an Idempotency-Key is remembered.
+	idemTTL time.Duration
 }
 
-func NewScoreHandler(redisClient *redis.Client) *ScoreHandler {
+func NewScoreHandler(lb store.LeaderboardStore, kv store.KV, tierSet tiers.Set, publisher events.Publisher, idemTTL time.Duration) *ScoreHandler {
 	return &ScoreHandler{
-		redisClient: redisClient,
+		store:   lb,
+		kv:      kv,
+		tiers:   tierSet.For(lb.Board()),
+		events:  publisher,
+		idemTTL: idemTTL,
 	}
 }
 
@@ -48,44 +57,24 @@ func (h *ScoreHandler) SubmitScore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	idemKey := r.Header.Get("Idempotency-Key")
-	const idemTTL = 2 * time.Minute
-	if idemKey != "" {
-		ctx := r.Context()
-		key := "idem:score:" + idemKey
-		created, err := h.redisClient.SetNX(ctx, key, "1", idemTTL).Result()
-		if err != nil {
-			log.Printf("Failed to set idempotency key in Redis: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
-		}
-		if !created {
-			score, err := h.redisClient.ZScore(ctx, scoreSet, req.Player).Result()
-			if err != nil && err != redis.Nil {
-				log.Printf("Failed to get score during idempotent replay: %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"status":            "success",
-				"message":           "Idempotent replay; score unchanged",
-				"player":            req.Player,
-				"score":             score,
-				"idempotent_replay": true,
-			})
-			return
-		}
-	}
-
-	ctx := r.Context()
-	score, err := h.redisClient.ZIncrBy(ctx, scoreSet, float64(req.Score), req.Player).Result()
+	score, replay, err := h.submit(r.Context(), req, r.Header.Get("Idempotency-Key"))
 	if err != nil {
-		log.Printf("Failed to update score in Redis: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	if replay {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":            "success",
+			"message":           "Idempotent replay; score unchanged",
+			"player":            req.Player,
+			"score":             score,
+			"idempotent_replay": true,
+		})
+		return
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
@@ -109,10 +98,9 @@ func (h *ScoreHandler) GetScore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-	score, err := h.redisClient.ZScore(ctx, scoreSet, player).Result()
+	score, err := h.score(r.Context(), player)
 	if err != nil {
-		if err == redis.Nil {
+		if err == errPlayerNotFound {
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"status":  "success",
@@ -122,7 +110,6 @@ func (h *ScoreHandler) GetScore(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		log.Printf("Failed to get score from Redis: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -135,3 +122,64 @@ func (h *ScoreHandler) GetScore(w http.ResponseWriter, r *http.Request) {
 		"score":   score,
 	})
 }
+
+// submit adds req.Score to the player's total. When idemKey was already seen
+// within the idempotency window the score is left untouched, the current total
+// is returned and replay is true.
+func (h *ScoreHandler) submit(ctx context.Context, req models.ScoreRequest, idemKey string) (score float64, replay bool, err error) {
+	if idemKey != "" {
+		key := keys.Idempotency(idemKey)
+		created, err := h.kv.SetNX(ctx, key, []byte("1"), h.idemTTL)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to set idempotency key", "err", err)
+			return 0, false, err
+		}
+		if !created {
+			score, err := h.store.Score(ctx, req.Player)
+			if err != nil && err != store.ErrNotFound {
+				slog.ErrorContext(ctx, "Failed to get score during idempotent replay", "err", err)
+				return 0, false, err
+			}
+			return score, true, nil
+		}
+	}
+
+	inc, err := h.store.Increment(ctx, req.Player, float64(req.Score))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update score", "err", err)
+		return 0, false, err
+	}
+	metrics.Submissions.WithLabelValues(h.store.Board()).Inc()
+
+	if h.tiers != nil {
+		from := ""
+		if inc.Existed {
+			from = h.tiers.Classify(inc.OldScore, percentileOf(inc.OldBelow, inc.OldTotal))
+		}
+		to := h.tiers.Classify(inc.Score, percentileOf(inc.NewBelow, inc.NewTotal))
+		if from != to && h.events != nil {
+			h.events.Publish(events.Event{
+				Type:   events.TypeTierChanged,
+				Board:  h.store.Board(),
+				Player: req.Player,
+				From:   from,
+				To:     to,
+				Score:  inc.Score,
+			})
+		}
+	}
+	return inc.Score, false, nil
+}
+
+// score returns errPlayerNotFound when player has no score.
+func (h *ScoreHandler) score(ctx context.Context, player string) (float64, error) {
+	score, err := h.store.Score(ctx, player)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return 0, errPlayerNotFound
+		}
+		slog.ErrorContext(ctx, "Failed to get score", "err", err)
+		return 0, err
+	}
+	return score, nil
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+	"net/http"
+)
+
+// SubmitScoreV2 handles POST /v2/score. New totals are reported with 201,
+// idempotent replays with 200.
+func (h *ScoreHandler) SubmitScoreV2(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	var req models.ScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidBody, "request body must be a JSON score object")
+		return
+	}
+
+	if req.Player == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodePlayerRequired, "player is required")
+		return
+	}
+
+	if req.Score <= 0 {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidScore, "score must be a positive number")
+		return
+	}
+
+	score, replay, err := h.submit(r.Context(), req, r.Header.Get("Idempotency-Key"))
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	status := http.StatusCreated
+	if replay {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, models.ScoreResponse{
+		Player:           req.Player,
+		Score:            score,
+		IdempotentReplay: replay,
+	})
+}
+
+// GetScoreV2 handles GET /v2/score?player=:id
+// Unknown players are reported as 404 player_not_found.
+func (h *ScoreHandler) GetScoreV2(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "")
+		return
+	}
+
+	player := r.URL.Query().Get("player")
+	if player == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodePlayerRequired, "player query parameter is required")
+		return
+	}
+
+	score, err := h.score(r.Context(), player)
+	if err != nil {
+		if err == errPlayerNotFound {
+			problem.Write(w, r, http.StatusNotFound, problem.CodePlayerNotFound, "no score recorded for "+player)
+			return
+		}
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ScoreResponse{
+		Player: player,
+		Score:  score,
+	})
+}
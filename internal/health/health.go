@@ -0,0 +1,152 @@
+// Package health runs dependency checks for the readiness endpoint and
+// caches their results so frequent probes do not load the dependencies.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status is the outcome of a check or of a whole report.
+type Status string
+
+const (
+	StatusUp Status = "up"
+	// StatusDegraded is reported but does not make the service unready.
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     Status                 `json:"status"`
+	DurationMS float64                `json:"duration_ms"`
+	Error      string                 `json:"error,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report combines the results of every check. Its status is the worst of
+// theirs, or down while the server is shutting down.
+type Report struct {
+	Status     Status            `json:"status"`
+	Draining   bool              `json:"draining,omitempty"`
+	CheckedAt  time.Time         `json:"checked_at"`
+	DurationMS float64           `json:"duration_ms"`
+	Checks     map[string]Result `json:"checks"`
+}
+
+// Ready reports whether the service should receive traffic.
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// Check inspects one dependency. It should return promptly once ctx is
+// done; DurationMS is filled in by the Checker.
+type Check func(ctx context.Context) Result
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs its checks concurrently and reuses the report for ttl.
+type Checker struct {
+	ttl     time.Duration
+	timeout time.Duration
+	checks  []namedCheck
+
+	draining atomic.Bool
+
+	// mu is held while checks run, so concurrent probes share one run.
+	mu      sync.Mutex
+	last    Report
+	expires time.Time
+}
+
+// NewChecker returns a Checker caching reports for ttl and giving each check
+// timeout to finish.
+func NewChecker(ttl, timeout time.Duration) *Checker {
+	return &Checker{ttl: ttl, timeout: timeout}
+}
+
+// Add registers a check. Call it before the Checker is used.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain marks the service as shutting down, which makes reports fail.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Report returns the cached report, running the checks when it has expired.
+func (c *Checker) Report(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.After(c.expires) {
+		c.last = c.run(ctx)
+		c.expires = c.last.CheckedAt.Add(c.ttl)
+	}
+
+	report := c.last
+	if c.draining.Load() {
+		report.Draining = true
+		report.Status = StatusDown
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context) Report {
+	// Probes are cut short by their client; the checks still finish so the
+	// cached result is complete.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			began := time.Now()
+			res := nc.check(ctx)
+			res.DurationMS = millis(time.Since(began))
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:     StatusUp,
+		CheckedAt:  start,
+		DurationMS: millis(time.Since(start)),
+		Checks:     make(map[string]Result, len(c.checks)),
+	}
+	for i, nc := range c.checks {
+		res := results[i]
+		report.Checks[nc.name] = res
+		if worse(res.Status, report.Status) {
+			report.Status = res.Status
+		}
+	}
+	return report
+}
+
+func worse(a, b Status) bool {
+	rank := map[Status]int{StatusUp: 0, StatusDegraded: 1, StatusDown: 2}
+	return rank[a] > rank[b]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Down is a failed Result carrying err.
+func Down(err error) Result {
+	return Result{Status: StatusDown, Error: err.Error()}
+}
@@ -0,0 +1,181 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-redis/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPing is down when Redis does not answer PING and degraded when it
+// takes longer than maxLatency.
+func RedisPing(rdb redis.UniversalClient, maxLatency time.Duration) Check {
+	return func(ctx context.Context) Result {
+		start := time.Now()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			return Down(err)
+		}
+		latency := time.Since(start)
+		res := Result{Status: StatusUp, Details: map[string]interface{}{
+			"latency_ms":     millis(latency),
+			"max_latency_ms": millis(maxLatency),
+		}}
+		if latency > maxLatency {
+			res.Status = StatusDegraded
+		}
+		return res
+	}
+}
+
+// sustainedTimeoutChecks is how many checks in a row must see commands time
+// out waiting for a connection before the pool is reported down.
+const sustainedTimeoutChecks = 3
+
+// RedisPool is degraded when the share of pooled connections in use reaches
+// maxUsage, or when commands have timed out waiting for one since the
+// previous check. Saturation alone never fails readiness: that would pull
+// the busiest replicas out and push their traffic onto the rest. Only
+// timeouts in sustainedTimeoutChecks checks in a row make it down. Cluster
+// clients pool per node, so only timeouts are judged there.
+func RedisPool(rdb redis.UniversalClient, maxUsage float64) Check {
+	var lastTimeouts atomic.Uint32
+	lastTimeouts.Store(rdb.PoolStats().Timeouts)
+	var timeoutChecks atomic.Int32
+
+	return func(ctx context.Context) Result {
+		stats := rdb.PoolStats()
+		inUse := stats.TotalConns - stats.IdleConns
+		newTimeouts := stats.Timeouts - lastTimeouts.Swap(stats.Timeouts)
+
+		res := Result{Status: StatusUp, Details: map[string]interface{}{
+			"total_conns":  stats.TotalConns,
+			"idle_conns":   stats.IdleConns,
+			"in_use":       inUse,
+			"timeouts":     stats.Timeouts,
+			"new_timeouts": newTimeouts,
+		}}
+		if c, ok := rdb.(*redis.Client); ok {
+			size := c.Options().PoolSize
+			usage := float64(inUse) / float64(size)
+			res.Details["pool_size"] = size
+			res.Details["usage"] = usage
+			if usage >= maxUsage {
+				res.Status = StatusDegraded
+				res.Error = fmt.Sprintf("%d of %d connections in use", inUse, size)
+			}
+		}
+		if newTimeouts == 0 {
+			timeoutChecks.Store(0)
+			return res
+		}
+		res.Status = StatusDegraded
+		if n := timeoutChecks.Add(1); n >= sustainedTimeoutChecks {
+			res.Status = StatusDown
+			res.Error = fmt.Sprintf("commands timed out waiting for a connection in %d checks in a row", n)
+		}
+		return res
+	}
+}
+
+// RedisReplication reads INFO replication from the primary (every primary
+// in a cluster) and is degraded when a replica is not online or has not
+// acknowledged the primary for longer than maxLag. Without replicas it is
+// always up.
+func RedisReplication(rdb redis.UniversalClient, maxLag time.Duration) Check {
+	return func(ctx context.Context) Result {
+		var (
+			mu       sync.Mutex
+			replicas []map[string]string
+		)
+		collect := func(ctx context.Context, c redis.UniversalClient) error {
+			info, err := c.Info(ctx, "replication").Result()
+			if err != nil {
+				return err
+			}
+			found := parseReplicas(info)
+			mu.Lock()
+			replicas = append(replicas, found...)
+			mu.Unlock()
+			return nil
+		}
+
+		var err error
+		if cc, ok := rdb.(*redis.ClusterClient); ok {
+			err = cc.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+				return collect(ctx, c)
+			})
+		} else {
+			err = collect(ctx, rdb)
+		}
+		if err != nil {
+			// Lag matters to readers of replicas, not to this service's
+			// ability to serve, so a failure here is not fatal.
+			return Result{Status: StatusDegraded, Error: err.Error()}
+		}
+
+		res := Result{Status: StatusUp, Details: map[string]interface{}{
+			"replicas":        len(replicas),
+			"max_lag_seconds": maxLag.Seconds(),
+		}}
+		var worst int
+		for _, r := range replicas {
+			lag, _ := strconv.Atoi(r["lag"])
+			if lag > worst {
+				worst = lag
+			}
+			if r["state"] != "online" {
+				res.Status = StatusDegraded
+				res.Error = fmt.Sprintf("replica %s:%s is %s", r["ip"], r["port"], r["state"])
+			}
+		}
+		res.Details["lag_seconds"] = worst
+		if time.Duration(worst)*time.Second > maxLag {
+			res.Status = StatusDegraded
+			res.Error = fmt.Sprintf("replica lag %ds exceeds %s", worst, maxLag)
+		}
+		return res
+	}
+}
+
+// parseReplicas extracts the slaveN lines of INFO replication, e.g.
+// "slave0:ip=10.0.0.2,port=6379,state=online,offset=1234,lag=0".
+func parseReplicas(info string) []map[string]string {
+	var out []map[string]string
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.HasPrefix(key, "slave") {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(key, "slave")); err != nil {
+			continue
+		}
+		fields := map[string]string{}
+		for _, kv := range strings.Split(value, ",") {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				fields[k] = v
+			}
+		}
+		out = append(out, fields)
+	}
+	return out
+}
+
+// Store checks that the leaderboard answers, for backends with no
+// dependency of their own to probe.
+func Store(lb store.LeaderboardStore) Check {
+	return func(ctx context.Context) Result {
+		n, err := lb.Card(ctx)
+		if err != nil {
+			return Down(err)
+		}
+		return Result{Status: StatusUp, Details: map[string]interface{}{"players": n}}
+	}
+}
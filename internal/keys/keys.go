@@ -0,0 +1,77 @@
+// Package keys names every Redis key the service uses.
+//
+// Keys derived from a leaderboard carry its name as a Redis Cluster hash
+// tag, "{scores}:stats" for the "scores" board, so scripts and transactions
+// touching a board and its indexes stay within one slot. The board's sorted
+// set keeps its bare name for compatibility with existing data: a key
+// without braces is hashed in full, which puts "scores" in the same slot as
+// "{scores}:...".
+package keys
+
+import "strconv"
+
+// Board returns the sorted set holding the scores of board.
+func Board(board string) string {
+	return board
+}
+
+// Tag returns the hash tag shared by all keys of board.
+func Tag(board string) string {
+	return "{" + board + "}"
+}
+
+// Stats returns the hash holding the running "sum" and "count" of board.
+func Stats(board string) string {
+	return Tag(board) + ":stats"
+}
+
+// Distinct returns the sorted set indexing each distinct score of board.
+func Distinct(board string) string {
+	return Tag(board) + ":distinct"
+}
+
+// DistinctCounts returns the hash counting players per distinct score.
+func DistinctCounts(board string) string {
+	return Tag(board) + ":distinct:counts"
+}
+
+// Version returns the hash counting the writes that changed board, under
+// the field "board", and holding the board's count as of each player's
+// last write under "player:NAME". The time of the last such write is kept
+// under the field prefixed "modified:". A player's fields are deleted when
+// the player is removed, so the hash only grows with the board.
+func Version(board string) string {
+	return Tag(board) + ":version"
+}
+
+// Changes returns the Pub/Sub channel announcing the player of each write
+// that changed board.
+func Changes(board string) string {
+	return Tag(board) + ":changes"
+}
+
+// Idempotency returns the marker key of a score submission's
+// Idempotency-Key header.
+func Idempotency(key string) string {
+	return "idem:score:" + key
+}
+
+// RateLimit returns the bucket of limit number i of a rate limit policy
+// for the client identified by id, e.g. "ip:10.0.0.1".
+func RateLimit(policy string, i int, id string) string {
+	return "ratelimit:" + policy + ":" + strconv.Itoa(i) + ":" + id
+}
+
+// Ban returns the key marking client, an address or IPv6 network, as
+// banned. The client is a hash tag so it shares a slot with its Strikes.
+func Ban(client string) string {
+	return "ban:{" + client + "}"
+}
+
+// BanPattern matches every Ban key.
+const BanPattern = "ban:{*}"
+
+// Strikes returns the counter of client's recent rate limit violations.
+func Strikes(client string) string {
+	return "strikes:{" + client + "}"
+}
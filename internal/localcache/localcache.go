@@ -0,0 +1,133 @@
+// Package localcache keeps values in process for a short time, evicting the
+// least recently used once their total size reaches a limit.
+package localcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is safe for concurrent use. Each value carries a tag, and
+// Invalidate drops every value under a tag at once.
+type Cache struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	size  int64
+	order *list.List // of *item, most recently used first
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	// epoch advances with every invalidation; see Set.
+	epoch uint64
+}
+
+type item struct {
+	key, tag string
+	value    any
+	size     int64
+	expires  time.Time
+}
+
+// New returns a cache holding up to maxBytes of values, each for ttl.
+func New(maxBytes int64, ttl time.Duration) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Get returns the value under key unless it is absent or has expired.
+func (c *Cache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*item)
+	if !time.Now().Before(it.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return it.value, true
+}
+
+// Epoch returns a mark to pass to Set.
+func (c *Cache) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// Set stores value, which takes size bytes, under key and tag. It is
+// skipped when anything was invalidated since epoch was taken, since the
+// value may have been read before the change that caused it, and when the
+// value alone would exceed the limit.
+func (c *Cache) Set(key, tag string, value any, size int64, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if epoch != c.epoch || size > c.maxBytes {
+		return
+	}
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	it := &item{key: key, tag: tag, value: value, size: size, expires: time.Now().Add(c.ttl)}
+	c.items[key] = c.order.PushFront(it)
+	if c.tags[tag] == nil {
+		c.tags[tag] = make(map[string]struct{})
+	}
+	c.tags[tag][key] = struct{}{}
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops the values under each tag.
+func (c *Cache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(c.items[key])
+		}
+	}
+}
+
+// Purge drops every value.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.size = 0
+	c.order.Init()
+	clear(c.items)
+	clear(c.tags)
+}
+
+// Size returns the number of values held and their total size.
+func (c *Cache) Size() (entries int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items), c.size
+}
+
+func (c *Cache) remove(el *list.Element) {
+	it := c.order.Remove(el).(*item)
+	delete(c.items, it.key)
+	if keys := c.tags[it.tag]; keys != nil {
+		delete(keys, it.key)
+		if len(keys) == 0 {
+			delete(c.tags, it.tag)
+		}
+	}
+	c.size -= it.size
+}
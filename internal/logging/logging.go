@@ -0,0 +1,148 @@
+// Package logging configures log/slog for the service and carries
+// per-request details through the context so log lines can be correlated.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go-redis/internal/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// level is shared by every handler Setup creates so SetLevel applies at
+// once, including on config reload.
+var level = new(slog.LevelVar)
+
+// Setup makes a logger writing to w in the configured format the default
+// for slog and the log package.
+func Setup(w io.Writer, cfg config.LoggingConfig) error {
+	if err := SetLevel(cfg.Level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.Format {
+	case config.LogJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case config.LogText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return nil
+}
+
+// SetLevel changes the minimum level logged: debug, info, warn or error.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	level.Set(l)
+	return nil
+}
+
+// RequestInfo collects what the access log reports about a request.
+// Middleware below the logger fills in the fields it decides through the
+// Set functions, which may run after the log line is written when a
+// request times out.
+type RequestInfo struct {
+	ID       string
+	ClientIP string
+	// APIKeyID identifies the caller's API key without revealing it.
+	APIKeyID string
+	// Cache is the response cache's result: hit, local_hit, stale, miss,
+	// coalesced, bypass or error.
+	Cache string
+	// Access is the access list's decision when it overrode the rate
+	// limiter: exempt, deny or banned.
+	Access string
+	// RateLimit is the rate limiter's decision: allow, deny or reject.
+	RateLimit string
+	// RateLimitPolicy is the policy whose limit was reported.
+	RateLimitPolicy string
+}
+
+type infoKey struct{}
+
+// requestInfo guards a request's RequestInfo.
+type requestInfo struct {
+	mu   sync.Mutex
+	info RequestInfo
+}
+
+// WithRequestInfo returns ctx carrying info for the Set functions to fill in.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, infoKey{}, &requestInfo{info: info})
+}
+
+// Info returns a copy of the request's RequestInfo as it stands, or the
+// zero value outside a logged request.
+func Info(ctx context.Context) RequestInfo {
+	ri, ok := ctx.Value(infoKey{}).(*requestInfo)
+	if !ok {
+		return RequestInfo{}
+	}
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	return ri.info
+}
+
+// update applies fn to the request's RequestInfo, if any.
+func update(ctx context.Context, fn func(*RequestInfo)) {
+	ri, ok := ctx.Value(infoKey{}).(*requestInfo)
+	if !ok {
+		return
+	}
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	fn(&ri.info)
+}
+
+// SetCache records the response cache's result.
+func SetCache(ctx context.Context, result string) {
+	update(ctx, func(info *RequestInfo) { info.Cache = result })
+}
+
+// SetAccess records the access list's decision.
+func SetAccess(ctx context.Context, decision string) {
+	update(ctx, func(info *RequestInfo) { info.Access = decision })
+}
+
+// SetRateLimit records the rate limiter's decision and, when a limit was
+// reported, its policy.
+func SetRateLimit(ctx context.Context, decision, policy string) {
+	update(ctx, func(info *RequestInfo) { info.RateLimit, info.RateLimitPolicy = decision, policy })
+}
+
+// contextHandler adds the request and trace IDs from the context to every
+// record logged with one.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ri, ok := ctx.Value(infoKey{}).(*requestInfo); ok {
+		// The ID is set before the request is served and never changes.
+		r.AddAttrs(slog.String("request_id", ri.info.ID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
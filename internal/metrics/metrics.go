@@ -0,0 +1,176 @@
+// Package metrics defines the service's Prometheus metrics and serves them
+// from its own registry.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric below plus the Go runtime and process
+// collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by route pattern, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	HTTPTimeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_request_timeouts_total",
+		Help: "Requests answered with 408 because they exceeded the request timeout.",
+	})
+
+	RateLimitDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_decisions_total",
+		Help: "Rate limiter decisions, allowed, denied (429) or rejected (too many clients), by the policy reported.",
+	}, []string{"decision", "policy"})
+
+	RateLimitFallbacks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ratelimit_fallbacks_total",
+		Help: "Decisions made by the local limiter because Redis was unavailable.",
+	})
+
+	AccessDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "access_denials_total",
+		Help: "Requests refused with 403 by reason: deny (deny list or not on the allow list) or banned.",
+	}, []string{"reason"})
+
+	Bans = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "access_bans_total",
+		Help: "Clients banned for repeated rate limit violations.",
+	})
+
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Response cache lookups by result: local_hit (in process), hit, stale, miss, coalesced (waited for another request's miss), bypass or error.",
+	}, []string{"result"})
+
+	CacheWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "cache_write_errors_total",
+		Help: "Responses that could not be stored in the cache.",
+	})
+
+	RedisCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Redis command latency by command and outcome; pipelines count as one.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command", "status"})
+
+	Submissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "leaderboard_submissions_total",
+		Help: "Score submissions applied, by leaderboard. Idempotent replays are not counted.",
+	}, []string{"board"})
+)
+
+// activeVisitors reports the clients the rate limiter is tracking.
+var activeVisitors atomic.Pointer[func() int]
+
+// localCache reports the entries and bytes held by the in-process cache.
+var localCache atomic.Pointer[func() (int, int64)]
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests, HTTPDuration, HTTPTimeouts,
+		RateLimitDecisions, RateLimitFallbacks, AccessDenials, Bans,
+		CacheLookups, CacheWriteErrors,
+		RedisCommandDuration, Submissions,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "ratelimit_active_visitors",
+			Help: "Client buckets currently tracked by the in-process rate limiter.",
+		}, func() float64 {
+			if fn := activeVisitors.Load(); fn != nil {
+				return float64((*fn)())
+			}
+			return 0
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_local_entries",
+			Help: "Responses held by the in-process cache layer.",
+		}, func() float64 {
+			if fn := localCache.Load(); fn != nil {
+				n, _ := (*fn)()
+				return float64(n)
+			}
+			return 0
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "cache_local_bytes",
+			Help: "Approximate size of the responses held by the in-process cache layer.",
+		}, func() float64 {
+			if fn := localCache.Load(); fn != nil {
+				_, size := (*fn)()
+				return float64(size)
+			}
+			return 0
+		}),
+		players,
+	)
+}
+
+// ObserveVisitors sets the source of the active visitors gauge.
+func ObserveVisitors(fn func() int) {
+	activeVisitors.Store(&fn)
+}
+
+// ObserveLocalCache sets the source of the in-process cache gauges.
+func ObserveLocalCache(fn func() (entries int, bytes int64)) {
+	localCache.Store(&fn)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Instrument records the count and latency of requests to next under route,
+// the pattern it is registered with.
+func Instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+		HTTPRequests.With(labels).Inc()
+		HTTPDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
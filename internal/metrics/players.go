@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go-redis/internal/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// playersTimeout bounds the store lookups made during a scrape.
+const playersTimeout = 2 * time.Second
+
+var players = &playersCollector{
+	desc: prometheus.NewDesc("leaderboard_players",
+		"Players with a score, by leaderboard, read from the store at scrape time.",
+		[]string{"board"}, nil),
+	stores: map[string]store.LeaderboardStore{},
+}
+
+// ObserveBoard adds lb to the leaderboard_players gauge.
+func ObserveBoard(lb store.LeaderboardStore) {
+	players.mu.Lock()
+	defer players.mu.Unlock()
+	players.stores[lb.Board()] = lb
+}
+
+type playersCollector struct {
+	desc   *prometheus.Desc
+	mu     sync.Mutex
+	stores map[string]store.LeaderboardStore
+}
+
+func (c *playersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *playersCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), playersTimeout)
+	defer cancel()
+	for board, lb := range c.stores {
+		n, err := lb.Card(ctx)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.desc, err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), board)
+	}
+}
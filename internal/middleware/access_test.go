@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"go-redis/internal/bans"
+	"go-redis/internal/clientip"
+)
+
+func TestAccess(t *testing.T) {
+	prefixes := func(ss ...string) []netip.Prefix {
+		out := make([]netip.Prefix, len(ss))
+		for i, s := range ss {
+			out[i] = netip.MustParsePrefix(s)
+		}
+		return out
+	}
+	tests := []struct {
+		name                string
+		allow, deny, exempt []netip.Prefix
+		client              string
+		status              int
+		exempted            bool
+	}{
+		{name: "no lists", client: "192.0.2.1", status: http.StatusOK},
+		{name: "denied", deny: prefixes("192.0.2.0/24"), client: "192.0.2.1", status: http.StatusForbidden},
+		{name: "not denied", deny: prefixes("192.0.2.0/24"), client: "198.51.100.1", status: http.StatusOK},
+		{name: "allowed but not exempt", allow: prefixes("192.0.2.0/24"), client: "192.0.2.1", status: http.StatusOK},
+		{name: "not on the allow list", allow: prefixes("192.0.2.0/24"), client: "198.51.100.1", status: http.StatusForbidden},
+		{name: "more specific allow wins", allow: prefixes("192.0.2.1/32"), deny: prefixes("192.0.2.0/24"), client: "192.0.2.1", status: http.StatusOK},
+		{name: "more specific deny wins", allow: prefixes("192.0.2.0/24"), deny: prefixes("192.0.2.1/32"), client: "192.0.2.1", status: http.StatusForbidden},
+		{name: "deny wins a tie", allow: prefixes("192.0.2.0/24"), deny: prefixes("192.0.2.0/24"), client: "192.0.2.1", status: http.StatusForbidden},
+		{name: "exempt", exempt: prefixes("192.0.2.0/24"), client: "192.0.2.1", status: http.StatusOK, exempted: true},
+		{name: "exempt but denied", exempt: prefixes("192.0.2.0/24"), deny: prefixes("192.0.2.1/32"), client: "192.0.2.1", status: http.StatusForbidden},
+		{name: "exempt but not allowed", exempt: prefixes("192.0.2.0/24"), allow: prefixes("198.51.100.0/24"), client: "192.0.2.1", status: http.StatusForbidden},
+		{name: "exempt from bans", exempt: prefixes("203.0.113.0/24"), client: "203.0.113.7", status: http.StatusOK, exempted: true},
+		{name: "banned", client: "203.0.113.7", status: http.StatusForbidden},
+		{name: "banned IPv6 network", client: "2001:db8:1:2::99", status: http.StatusForbidden},
+	}
+
+	ctx := context.Background()
+	bs := bans.NewMemoryStore()
+	for _, client := range []string{"203.0.113.7", "2001:db8:1:2::/64"} {
+		if _, err := bs.Strike(ctx, client, bans.Policy{Threshold: 1, Window: time.Minute, Duration: time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range tests {
+		var exempted bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			exempted = rateLimitExempt(r.Context())
+		})
+		h := NewAccess(AccessConfig{Allow: tt.allow, Deny: tt.deny, Exempt: tt.exempt, Bans: bs, IPv6Prefix: 64})(next)
+		r := httptest.NewRequest("GET", "/top", nil)
+		r = r.WithContext(clientip.NewContext(r.Context(), netip.MustParseAddr(tt.client)))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if exempted != tt.exempted {
+			t.Errorf("%s: exempt from rate limiting = %t, want %t", tt.name, exempted, tt.exempted)
+		}
+	}
+}
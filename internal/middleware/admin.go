@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"go-redis/internal/problem"
+)
+
+// NewAdminAuth only lets through requests carrying token as a bearer token
+// in the Authorization header.
+func NewAdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "a valid admin token is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
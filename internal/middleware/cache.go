@@ -3,101 +3,406 @@ package middleware
 import (
 	"bytes"
 	"context"
+	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
 	"encoding/hex"
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"go-redis/internal/localcache"
+	"go-redis/internal/logging"
+	"go-redis/internal/metrics"
+	"go-redis/internal/store"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// CacheStatusHeader reports where a cached route's response came from:
+// HIT-L1 for the in-process layer, HIT-L2 for the store, MISS otherwise.
+const CacheStatusHeader = "X-Cache"
+
+// lockPollInterval is how often a request waiting on another replica's
+// cache fill checks for the entry.
+const lockPollInterval = 25 * time.Millisecond
+
+// Versions reports how far the data behind a response has moved on; see
+// store.LeaderboardStore.Version.
+type Versions interface {
+	Version(ctx context.Context, player string) (store.Version, error)
+}
+
 type CacheConfig struct {
-	DefaultTTL time.Duration
+	DefaultTTL      time.Duration
 	SkipCacheHeader string
-	CacheControl bool
+	CacheControl    bool
+	// Versions invalidates entries: one is served only while the version
+	// it was built at is current. Without it entries live out their TTL.
+	Versions Versions
+	// PlayerParam names the query parameter of responses that describe a
+	// single player. Their entries follow the player's version instead of
+	// the board's, so writes by other players leave them alone.
+	PlayerParam string
+	// Namespace separates entries built under different settings.
+	Namespace string
+
+	// StaleWhileRevalidate is how long past expiry an entry is still
+	// served while one request refreshes it. It only applies to entries
+	// built at the current version.
+	StaleWhileRevalidate time.Duration
+	// StaleAfterWrite is how long after the write that invalidated an
+	// entry it is still served, within StaleWhileRevalidate of expiry,
+	// while one request refreshes it. 0 never serves an invalidated entry.
+	StaleAfterWrite time.Duration
+	// StaleIfError is how long past expiry an entry stands in for a
+	// response that failed with a 5xx, or for the cache itself when the
+	// version or the entry cannot be read.
+	StaleIfError time.Duration
+	// LockTimeout bounds how long a replica holds the fill lock of an
+	// entry, and how long the others wait for it before building the
+	// response themselves. 0 disables the lock.
+	LockTimeout time.Duration
+	// EarlyExpiration scales how early, at random, entries are refreshed
+	// ahead of expiry; 1 is the usual choice, 0 disables it.
+	EarlyExpiration float64
+	// BuildTimeout bounds a fill, which outlives the request that started
+	// it when others share it.
+	BuildTimeout time.Duration
+
+	// Tasks tracks cache refreshes, which finish after the response is
+	// sent.
+	Tasks *Tasks
+	// Local, when set, keeps fresh entries in process, tagged "" for the
+	// board or with the player of PlayerParam. Whoever owns it invalidates
+	// those tags on writes; entries found there are served without
+	// checking their version.
+	Local *localcache.Cache
 }
 
 type cacheEntry struct {
 	Status  int
 	Headers http.Header
 	Body    []byte
+	// Version is the board or player version the entry was built at.
+	Version int64
+	// Expires is when the entry stops being fresh.
+	Expires time.Time
+	// Delta is how long the response took to build.
+	Delta time.Duration
 }
 
-func NewCache(rdb *redis.Client, config CacheConfig) func(http.Handler) http.Handler {
+// size approximates the memory the entry takes.
+func (e *cacheEntry) size() int64 {
+	n := len(e.Body)
+	for k, v := range e.Headers {
+		n += len(k)
+		for _, s := range v {
+			n += len(s)
+		}
+	}
+	return int64(n)
+}
+
+// fresh reports whether e can be served as is at version. Nearing expiry
+// it turns stale early with a probability that grows with the time it took
+// to build, so one request refreshes a hot entry before the others find it
+// expired.
+func (e *cacheEntry) fresh(version int64, now time.Time, beta float64) bool {
+	if e.Version != version {
+		return false
+	}
+	early := time.Duration(float64(e.Delta) * beta * -math.Log(1-rand.Float64()))
+	return now.Add(early).Before(e.Expires)
+}
+
+// NewCache serves GET responses from kv. A request finding no usable entry
+// builds it while concurrent requests for the same entry wait: within the
+// process through singleflight, across replicas through a lock in kv.
+// Stale entries are served while a background request refreshes them, and
+// in place of server errors. Conditional requests are answered from the
+// ETag and Last-Modified an entry was stored with.
+func NewCache(kv store.KV, config CacheConfig) func(http.Handler) http.Handler {
 	if config.DefaultTTL == 0 {
 		config.DefaultTTL = 5 * time.Minute
 	}
+	if config.BuildTimeout == 0 {
+		config.BuildTimeout = 30 * time.Second
+	}
+	group := &singleflight.Group{}
+	refreshing := &sync.Map{}
 
 	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != http.MethodGet {
-				next.ServeHTTP(w, r)
-				return
-			}
+		c := &cache{kv: kv, config: config, next: next, group: group, refreshing: refreshing}
+		return http.HandlerFunc(c.serve)
+	}
+}
 
-			if config.SkipCacheHeader != "" && r.Header.Get(config.SkipCacheHeader) != "" {
-				next.ServeHTTP(w, r)
-				return
-			}
+type cache struct {
+	kv     store.KV
+	config CacheConfig
+	next   http.Handler
+	group  *singleflight.Group
+	// refreshing holds the keys of entries being refreshed in the
+	// background, so stale hits start one refresh between them.
+	refreshing *sync.Map
+}
 
-			key := generateCacheKey(r)
-
-			cached, err := getFromCache(rdb, key)
-			if err == nil && cached != nil {
-				// Serve from cache
-				for k, v := range cached.Headers {
-					w.Header()[k] = v
-				}
-				w.WriteHeader(cached.Status)
-				w.Write(cached.Body)
-				return
-			}
+func (c *cache) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	if c.config.SkipCacheHeader != "" && r.Header.Get(c.config.SkipCacheHeader) != "" {
+		logging.SetCache(r.Context(), "bypass")
+		w.Header().Set(CacheStatusHeader, "MISS")
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := generateCacheKey(c.config.Namespace, r)
+	player := ""
+	if c.config.PlayerParam != "" {
+		player = r.URL.Query().Get(c.config.PlayerParam)
+	}
+
+	var epoch uint64
+	if c.config.Local != nil {
+		if v, ok := c.config.Local.Get(key); ok && time.Now().Before(v.(*cacheEntry).Expires) {
+			logging.SetCache(r.Context(), "local_hit")
+			metrics.CacheLookups.WithLabelValues("local_hit").Inc()
+			c.write(w, r, v.(*cacheEntry), "HIT-L1")
+			return
+		}
+		epoch = c.config.Local.Epoch()
+	}
+
+	// The version is read first: should a write land while the handler
+	// runs, the entry is stored under the older version and is not served
+	// as fresh.
+	var v store.Version
+	var versionErr error
+	if c.config.Versions != nil {
+		v, versionErr = c.config.Versions.Version(r.Context(), player)
+	}
+	version := v.Count
+	cached, err := getFromCache(r.Context(), c.kv, key)
+	if err = errors.Join(versionErr, err); err != nil {
+		slog.WarnContext(r.Context(), "Cache read failed", "err", err)
+		c.serveUnavailable(w, r, key, cached)
+		return
+	}
+
+	now := time.Now()
+	var result string
+	switch {
+	case cached != nil && cached.fresh(version, now, c.config.EarlyExpiration):
+		result = "hit"
+	case cached != nil && now.Before(cached.Expires.Add(c.config.StaleWhileRevalidate)) &&
+		(cached.Version == version || now.Before(v.Modified.Add(c.config.StaleAfterWrite))):
+		result = "stale"
+		if _, busy := c.refreshing.LoadOrStore(key, struct{}{}); !busy {
+			c.config.Tasks.Go(func() {
+				defer c.refreshing.Delete(key)
+				c.fill(r, key, version, false)
+			})
+		}
+	default:
+		entry, shared := c.fill(r, key, version, true)
+		if entry == nil {
+			// Joined a background refresh that left the entry to another
+			// replica.
+			entry = c.build(r, key, version, true)
+		}
+		result = "miss"
+		if shared {
+			result = "coalesced"
+		}
+		if entry.Status >= 500 && cached != nil && now.Before(cached.Expires.Add(c.config.StaleIfError)) {
+			result = "stale"
+		} else {
+			cached = entry
+		}
+	}
+	logging.SetCache(r.Context(), result)
+	metrics.CacheLookups.WithLabelValues(result).Inc()
 
-			rw := &responseRecorder{ResponseWriter: w, body: []byte{}}
+	status := "MISS"
+	if result == "hit" || result == "stale" {
+		status = "HIT-L2"
+	}
+	if c.config.Local != nil && result != "stale" && cached.Version == version && now.Before(cached.Expires) {
+		c.config.Local.Set(key, player, cached, cached.size(), epoch)
+	}
+	c.write(w, r, cached, status)
+}
 
-			next.ServeHTTP(rw, r)
+// serveUnavailable answers a request whose version or entry could not be
+// read. The entry found, or failing that the one held in process, stands in
+// for the response within StaleIfError of its expiry, since the failure is
+// most likely the store being down; otherwise the cache is bypassed.
+func (c *cache) serveUnavailable(w http.ResponseWriter, r *http.Request, key string, cached *cacheEntry) {
+	status := "HIT-L2"
+	if cached == nil && c.config.Local != nil {
+		if v, ok := c.config.Local.Get(key); ok {
+			cached, status = v.(*cacheEntry), "HIT-L1"
+		}
+	}
+	if cached != nil && time.Now().Before(cached.Expires.Add(c.config.StaleIfError)) {
+		logging.SetCache(r.Context(), "stale")
+		metrics.CacheLookups.WithLabelValues("stale").Inc()
+		c.write(w, r, cached, status)
+		return
+	}
+	logging.SetCache(r.Context(), "error")
+	metrics.CacheLookups.WithLabelValues("error").Inc()
+	w.Header().Set(CacheStatusHeader, "MISS")
+	c.next.ServeHTTP(w, r)
+}
 
-			if rw.status >= 200 && rw.status < 300 {
-				ttl := config.DefaultTTL
-				if config.CacheControl {
-					if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "" {
-						if maxAge, ok := parseMaxAge(cacheControl); ok {
-							ttl = time.Duration(maxAge) * time.Second
-						}
-					}
-				}
+// write sends entry, or 304 when it satisfies the request's preconditions.
+func (c *cache) write(w http.ResponseWriter, r *http.Request, entry *cacheEntry, status string) {
+	w.Header().Set(CacheStatusHeader, status)
+	if entry.Status >= 200 && entry.Status < 300 && notModified(r, entry.Headers) {
+		writeNotModified(w, entry.Headers)
+		return
+	}
+	writeEntry(w, entry)
+}
 
-				entry := &cacheEntry{
-					Status:  rw.status,
-					Headers: w.Header().Clone(),
-					Body:    rw.body,
-				}
+// fill builds the entry for key, joining a fill of the same entry already
+// under way in the process. shared reports whether the result went to
+// other requests too. Unless wait is set, it gives up on an entry another
+// replica is building and returns nil.
+func (c *cache) fill(r *http.Request, key string, version int64, wait bool) (entry *cacheEntry, shared bool) {
+	v, _, shared := c.group.Do(key+":"+strconv.FormatInt(version, 10), func() (interface{}, error) {
+		return c.build(r, key, version, wait), nil
+	})
+	return v.(*cacheEntry), shared
+}
 
-				if w.Header().Get("Cache-Control") == "" {
-					w.Header().Set("Cache-Control", 
-						"public, max-age="+strconv.Itoa(int(ttl.Seconds())))
-				}
+func (c *cache) build(r *http.Request, key string, version int64, wait bool) *cacheEntry {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), c.config.BuildTimeout)
+	defer cancel()
+
+	if c.config.LockTimeout > 0 {
+		// The lock holds a token of its own, so a replica whose lock
+		// expired mid-build cannot release the next holder's.
+		lock, token := key+":lock", []byte(crand.Text())
+		locked, err := c.kv.SetNX(ctx, lock, token, c.config.LockTimeout)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "Cache lock failed", "err", err)
+		case locked:
+			defer c.kv.DeleteIf(ctx, lock, token)
+		case !wait:
+			return nil
+		default:
+			if entry := c.await(ctx, key, version); entry != nil {
+				return entry
+			}
+		}
+	}
+
+	// The entry is built in full for every client; each request's own
+	// preconditions are checked against it when it is served.
+	req := r.WithContext(ctx)
+	req.Header = r.Header.Clone()
+	for _, k := range []string{"If-None-Match", "If-Modified-Since"} {
+		req.Header.Del(k)
+	}
+	rec := &responseRecorder{header: http.Header{}, body: []byte{}}
+	start := time.Now()
+	c.next.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	entry := &cacheEntry{
+		Status:  rec.status,
+		Headers: rec.header,
+		Body:    rec.body,
+		Version: version,
+		Delta:   time.Since(start),
+	}
+	if entry.Status < 200 || entry.Status >= 300 {
+		return entry
+	}
 
-				go setInCache(rdb, key, entry, ttl)
+	ttl := c.config.DefaultTTL
+	if c.config.CacheControl {
+		if cacheControl := rec.header.Get("Cache-Control"); cacheControl != "" {
+			if maxAge, ok := parseMaxAge(cacheControl); ok {
+				ttl = time.Duration(maxAge) * time.Second
 			}
+		}
+	}
+	if rec.header.Get("Cache-Control") == "" {
+		rec.header.Set("Cache-Control", c.cacheControl(ttl))
+	}
+	entry.Expires = time.Now().Add(ttl)
+
+	// Kept past expiry for as long as it may be served stale.
+	keep := ttl + max(c.config.StaleWhileRevalidate, c.config.StaleIfError)
+	if err := setInCache(ctx, c.kv, key, entry, keep); err != nil {
+		metrics.CacheWriteErrors.Inc()
+	}
+	return entry
+}
 
-			w.WriteHeader(rw.status)
-			w.Write(rw.body)
-		})
+// await polls for the entry another replica is building, returning nil
+// if it has not appeared within the lock timeout.
+func (c *cache) await(ctx context.Context, key string, version int64) *cacheEntry {
+	deadline := time.Now().Add(c.config.LockTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(lockPollInterval):
+		}
+		entry, err := getFromCache(ctx, c.kv, key)
+		if err != nil {
+			return nil
+		}
+		if entry != nil && entry.Version == version && time.Now().Before(entry.Expires) {
+			return entry
+		}
 	}
+	return nil
 }
 
-func generateCacheKey(r *http.Request) string {
+func (c *cache) cacheControl(ttl time.Duration) string {
+	s := "public, max-age=" + strconv.Itoa(int(ttl.Seconds()))
+	if c.config.StaleWhileRevalidate > 0 {
+		s += ", stale-while-revalidate=" + strconv.Itoa(int(c.config.StaleWhileRevalidate.Seconds()))
+	}
+	if c.config.StaleIfError > 0 {
+		s += ", stale-if-error=" + strconv.Itoa(int(c.config.StaleIfError.Seconds()))
+	}
+	return s
+}
+
+func writeEntry(w http.ResponseWriter, entry *cacheEntry) {
+	// The entry may be shared with concurrent requests.
+	copyHeader(w.Header(), entry.Headers)
+	w.WriteHeader(entry.Status)
+	w.Write(entry.Body)
+}
+
+func generateCacheKey(namespace string, r *http.Request) string {
 	hash := sha256.New()
 	hash.Write([]byte(r.Method))
 	hash.Write([]byte(r.URL.Path))
 	hash.Write([]byte(r.URL.RawQuery))
 	hash.Write([]byte(r.Header.Get("Accept")))
 	hash.Write([]byte(r.Header.Get("Accept-Language")))
-	return "cache:" + hex.EncodeToString(hash.Sum(nil))
+	return "cache:" + namespace + ":" + hex.EncodeToString(hash.Sum(nil))
 }
 
 func parseMaxAge(cacheControl string) (int, bool) {
@@ -113,25 +418,35 @@ func parseMaxAge(cacheControl string) (int, bool) {
 	return 0, false
 }
 
+// responseRecorder buffers a response, headers included, so the cache can
+// store it before it is sent.
 type responseRecorder struct {
-	http.ResponseWriter
+	header http.Header
 	status int
 	body   []byte
 }
 
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
 func (r *responseRecorder) WriteHeader(code int) {
-	r.status = code
+	if r.status == 0 {
+		r.status = code
+	}
 }
 
 func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
 	r.body = append(r.body, b...)
 	return len(b), nil
 }
 
-func getFromCache(rdb *redis.Client, key string) (*cacheEntry, error) {
-	ctx := context.Background()
-	data, err := rdb.Get(ctx, key).Bytes()
-	if err == redis.Nil {
+func getFromCache(ctx context.Context, kv store.KV, key string) (*cacheEntry, error) {
+	data, err := kv.Get(ctx, key)
+	if err == store.ErrNotFound {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -145,12 +460,11 @@ func getFromCache(rdb *redis.Client, key string) (*cacheEntry, error) {
 	return &entry, nil
 }
 
-func setInCache(rdb *redis.Client, key string, entry *cacheEntry, ttl time.Duration) error {
-	ctx := context.Background()
+func setInCache(ctx context.Context, kv store.KV, key string, entry *cacheEntry, ttl time.Duration) error {
 	var buf bytes.Buffer
 	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
 		return err
 	}
 
-	return rdb.Set(ctx, key, buf.Bytes(), ttl).Err()
+	return kv.Set(ctx, key, buf.Bytes(), ttl)
 }
@@ -0,0 +1,329 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-redis/internal/localcache"
+	"go-redis/internal/store"
+)
+
+// testVersions is a Versions whose answer the test sets.
+type testVersions struct {
+	mu  sync.Mutex
+	v   store.Version
+	err error
+}
+
+func (tv *testVersions) Version(ctx context.Context, player string) (store.Version, error) {
+	tv.mu.Lock()
+	defer tv.mu.Unlock()
+	return tv.v, tv.err
+}
+
+func (tv *testVersions) write() {
+	tv.mu.Lock()
+	defer tv.mu.Unlock()
+	tv.v = store.Version{Count: tv.v.Count + 1, Modified: time.Now()}
+}
+
+// failingKV fails every read while fail is set.
+type failingKV struct {
+	store.KV
+	fail atomic.Bool
+}
+
+func (kv *failingKV) Get(ctx context.Context, key string) ([]byte, error) {
+	if kv.fail.Load() {
+		return nil, errors.New("kv down")
+	}
+	return kv.KV.Get(ctx, key)
+}
+
+// testHandler answers with body, counting its calls. While gate is set,
+// each call first waits for it to be closed.
+type testHandler struct {
+	mu     sync.Mutex
+	body   string
+	status int
+	gate   chan struct{}
+	calls  atomic.Int32
+}
+
+func (h *testHandler) set(status int, body string, gate chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status, h.body, h.gate = status, body, gate
+}
+
+func (h *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls.Add(1)
+	h.mu.Lock()
+	status, body, gate := h.status, h.body, h.gate
+	h.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+func newTestKV(t *testing.T) *store.MemoryKV {
+	kv := store.NewMemoryKV(time.Minute)
+	t.Cleanup(kv.Stop)
+	return kv
+}
+
+func get(h http.Handler) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/top", nil))
+	return rec
+}
+
+func TestCacheCoalescesMisses(t *testing.T) {
+	const n = 20
+	next := &testHandler{}
+	gate := make(chan struct{})
+	next.set(http.StatusOK, "v1", gate)
+	h := NewCache(newTestKV(t), CacheConfig{DefaultTTL: time.Minute, Tasks: &Tasks{}})(next)
+
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, n)
+	for i := range recs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = get(h)
+		}()
+	}
+	// Let every request reach the fill before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("%d concurrent misses ran the handler %d times, want once", n, calls)
+	}
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK || rec.Body.String() != "v1" {
+			t.Errorf("request %d: %d %q", i, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+	tests := []struct {
+		name string
+		// expire lets the entry expire; write invalidates it.
+		expire, write   bool
+		staleAfterWrite time.Duration
+		// stale is whether the previous entry is served while the
+		// refresh runs, rather than the request waiting for it.
+		stale bool
+	}{
+		{name: "expired", expire: true, stale: true},
+		{name: "invalidated", write: true, stale: false},
+		{name: "invalidated within stale_after_write", write: true, staleAfterWrite: time.Minute, stale: true},
+		{name: "expired and invalidated", expire: true, write: true, stale: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := &testVersions{}
+			tasks := &Tasks{}
+			next := &testHandler{}
+			next.set(http.StatusOK, "v1", nil)
+			h := NewCache(newTestKV(t), CacheConfig{
+				DefaultTTL:           ttl,
+				Versions:             versions,
+				StaleWhileRevalidate: time.Minute,
+				StaleAfterWrite:      tt.staleAfterWrite,
+				Tasks:                tasks,
+			})(next)
+			get(h)
+
+			if tt.expire {
+				time.Sleep(2 * ttl)
+			}
+			if tt.write {
+				versions.write()
+			}
+			gate := make(chan struct{})
+			next.set(http.StatusOK, "v2", gate)
+			if !tt.stale {
+				close(gate)
+				if rec := get(h); rec.Body.String() != "v2" {
+					t.Fatalf("got %q, want the rebuilt entry", rec.Body)
+				}
+				return
+			}
+
+			for range 3 {
+				if rec := get(h); rec.Body.String() != "v1" || rec.Header().Get(CacheStatusHeader) != "HIT-L2" {
+					t.Fatalf("got %q (%s), want the stale entry", rec.Body, rec.Header().Get(CacheStatusHeader))
+				}
+			}
+			close(gate)
+			if err := tasks.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if calls := next.calls.Load(); calls != 2 {
+				t.Errorf("handler ran %d times, want once to build and once to refresh", calls)
+			}
+			if rec := get(h); rec.Body.String() != "v2" {
+				t.Errorf("after the refresh got %q, want v2", rec.Body)
+			}
+		})
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+	tests := []struct {
+		name string
+		// fail breaks the response, the version or the entry read.
+		fail        func(next *testHandler, versions *testVersions, kv *failingKV)
+		local       bool
+		wantSource  string
+		staleWithin time.Duration
+		wantStale   bool
+	}{
+		{
+			name: "handler error",
+			fail: func(next *testHandler, _ *testVersions, _ *failingKV) {
+				next.set(http.StatusInternalServerError, "boom", nil)
+			},
+			wantSource: "HIT-L2", staleWithin: time.Minute, wantStale: true,
+		},
+		{
+			name: "version error",
+			fail: func(_ *testHandler, versions *testVersions, _ *failingKV) {
+				versions.err = errors.New("store down")
+			},
+			wantSource: "HIT-L2", staleWithin: time.Minute, wantStale: true,
+		},
+		{
+			name: "entry read error with an entry in process",
+			fail: func(_ *testHandler, _ *testVersions, kv *failingKV) {
+				kv.fail.Store(true)
+			},
+			local: true, wantSource: "HIT-L1", staleWithin: time.Minute, wantStale: true,
+		},
+		{
+			name: "entry read error with nothing in process",
+			fail: func(_ *testHandler, _ *testVersions, kv *failingKV) {
+				kv.fail.Store(true)
+			},
+			wantSource: "MISS", staleWithin: time.Minute, wantStale: false,
+		},
+		{
+			name: "handler error past stale_if_error",
+			fail: func(next *testHandler, _ *testVersions, _ *failingKV) {
+				next.set(http.StatusInternalServerError, "boom", nil)
+			},
+			wantSource: "MISS", staleWithin: 0, wantStale: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := &testVersions{}
+			kv := &failingKV{KV: newTestKV(t)}
+			next := &testHandler{}
+			next.set(http.StatusOK, "v1", nil)
+			config := CacheConfig{
+				DefaultTTL:   ttl,
+				Versions:     versions,
+				StaleIfError: tt.staleWithin,
+				Tasks:        &Tasks{},
+			}
+			if tt.local {
+				config.Local = localcache.New(1<<20, time.Minute)
+			}
+			h := NewCache(kv, config)(next)
+			get(h)
+
+			time.Sleep(2 * ttl)
+			tt.fail(next, versions, kv)
+			next.set(http.StatusInternalServerError, "boom", nil)
+			rec := get(h)
+			if got := rec.Header().Get(CacheStatusHeader); got != tt.wantSource {
+				t.Errorf("X-Cache = %s, want %s", got, tt.wantSource)
+			}
+			if stale := rec.Body.String() == "v1"; stale != tt.wantStale {
+				t.Errorf("got %d %q, want the previous entry: %t", rec.Code, rec.Body, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestCacheLockReleasedByItsHolderOnly(t *testing.T) {
+	const lockTimeout = 100 * time.Millisecond
+	kv := newTestKV(t)
+	config := CacheConfig{DefaultTTL: time.Minute, LockTimeout: lockTimeout, Tasks: &Tasks{}}
+	// Two caches sharing kv stand for two replicas.
+	slow, next := &testHandler{}, &testHandler{}
+	slowGate, nextGate := make(chan struct{}), make(chan struct{})
+	slow.set(http.StatusOK, "a", slowGate)
+	next.set(http.StatusOK, "b", nextGate)
+	a, b := NewCache(kv, config)(slow), NewCache(kv, config)(next)
+	lock := generateCacheKey("", httptest.NewRequest("GET", "/top", nil)) + ":lock"
+
+	aDone := make(chan struct{})
+	go func() {
+		get(a)
+		close(aDone)
+	}()
+	// a's lock expires mid-build and b takes it.
+	time.Sleep(lockTimeout + 50*time.Millisecond)
+	bDone := make(chan struct{})
+	go func() {
+		get(b)
+		close(bDone)
+	}()
+	for next.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	held, err := kv.Get(context.Background(), lock)
+	if err != nil {
+		t.Fatalf("b does not hold the lock: %v", err)
+	}
+
+	close(slowGate)
+	<-aDone
+	if got, err := kv.Get(context.Background(), lock); err != nil || string(got) != string(held) {
+		t.Errorf("a released b's lock: got %q, %v", got, err)
+	}
+	close(nextGate)
+	<-bDone
+	if _, err := kv.Get(context.Background(), lock); err != store.ErrNotFound {
+		t.Errorf("b left its lock behind: %v", err)
+	}
+}
+
+func TestCacheEntryFresh(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		entry   cacheEntry
+		version int64
+		beta    float64
+		want    bool
+	}{
+		{"unexpired", cacheEntry{Version: 1, Expires: now.Add(time.Minute)}, 1, 0, true},
+		{"expired", cacheEntry{Version: 1, Expires: now.Add(-time.Second)}, 1, 0, false},
+		{"invalidated", cacheEntry{Version: 1, Expires: now.Add(time.Minute)}, 2, 0, false},
+		{"slow to build and near expiry", cacheEntry{Version: 1, Expires: now.Add(time.Millisecond), Delta: time.Hour}, 1, 1, false},
+		{"early expiration disabled", cacheEntry{Version: 1, Expires: now.Add(time.Millisecond), Delta: time.Hour}, 1, 0, true},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.fresh(tt.version, now, tt.beta); got != tt.want {
+			t.Errorf("%s: fresh = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
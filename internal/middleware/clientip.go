@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+
+	"go-redis/internal/clientip"
+)
+
+// NewClientIP resolves each request's client address once and stores it
+// in the context for the middleware and handlers after it. Requests whose
+// address cannot be resolved pass through without one.
+func NewClientIP(resolver *clientip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, ok := resolver.Resolve(r); ok {
+				r = r.WithContext(clientip.NewContext(r.Context(), addr))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// getIP returns the address stored by NewClientIP.
+func getIP(r *http.Request) (netip.Addr, bool) {
+	return clientip.FromContext(r.Context())
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ConditionalConfig configures NewConditional.
+type ConditionalConfig struct {
+	// Versions supplies the board version the validators are derived from.
+	Versions Versions
+	// Namespace changes the ETags when the settings responses depend on
+	// change.
+	Namespace string
+}
+
+// NewConditional tags successful GET responses with an ETag and a
+// Last-Modified time derived from the board's version, and answers requests
+// whose If-None-Match or If-Modified-Since still match with 304 Not Modified
+// without running the handler.
+func NewConditional(config ConditionalConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			v, err := config.Versions.Version(r.Context(), "")
+			if err != nil {
+				slog.WarnContext(r.Context(), "Version read failed", "err", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			validators := http.Header{}
+			validators.Set("ETag", `W/"`+config.Namespace+"-"+strconv.FormatInt(v.Count, 10)+`"`)
+			if !v.Modified.IsZero() {
+				validators.Set("Last-Modified", v.Modified.UTC().Format(http.TimeFormat))
+			}
+			if notModified(r, validators) {
+				copyHeader(w.Header(), validators)
+				writeNotModified(w, w.Header())
+				return
+			}
+			next.ServeHTTP(&validatorWriter{ResponseWriter: w, validators: validators}, r)
+		})
+	}
+}
+
+// notModified evaluates the request's If-None-Match, or failing that its
+// If-Modified-Since, against the ETag and Last-Modified in h.
+func notModified(r *http.Request, h http.Header) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := h.Get("ETag")
+		return etag != "" && etagMatch(inm, etag)
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(ims)
+}
+
+// etagMatch reports whether the If-None-Match list matches etag, using the
+// weak comparison RFC 9110 prescribes for it.
+func etagMatch(list, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified answers 304 with the headers of h a full response would
+// have carried that still apply.
+func writeNotModified(w http.ResponseWriter, h http.Header) {
+	for _, k := range []string{"Cache-Control", "ETag", "Last-Modified", "Vary"} {
+		if v := h.Values(k); len(v) > 0 {
+			w.Header()[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+	for _, k := range []string{"Content-Type", "Content-Length"} {
+		w.Header().Del(k)
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+}
+
+// validatorWriter adds the validators to 2xx responses only, since a client
+// revalidating an error would be told to keep it.
+type validatorWriter struct {
+	http.ResponseWriter
+	validators  http.Header
+	wroteHeader bool
+}
+
+func (w *validatorWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code >= 200 && code < 300 {
+			copyHeader(w.Header(), w.validators)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *validatorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
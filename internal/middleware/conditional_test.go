@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-redis/internal/localcache"
+	"go-redis/internal/store"
+)
+
+func TestETagMatch(t *testing.T) {
+	tests := []struct {
+		list, etag string
+		want       bool
+	}{
+		{`"a"`, `"a"`, true},
+		{`"a"`, `"b"`, false},
+		{`W/"a"`, `"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{`W/"a"`, `W/"a"`, true},
+		{`*`, `"a"`, true},
+		{`"x", "y" ,W/"a"`, `"a"`, true},
+		{`"x", "y"`, `"a"`, false},
+		{`"a-1"`, `"a"`, false},
+		{`a`, `"a"`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatch(tt.list, tt.etag); got != tt.want {
+			t.Errorf("etagMatch(%s, %s) = %t, want %t", tt.list, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	validators := http.Header{
+		"Etag":          {`W/"ns-3"`},
+		"Last-Modified": {modified.Format(http.TimeFormat)},
+	}
+	at := func(d time.Duration) string { return modified.Add(d).Format(http.TimeFormat) }
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		h       http.Header
+		want    bool
+	}{
+		{name: "no preconditions", want: false},
+		{name: "weak match", headers: map[string]string{"If-None-Match": `W/"ns-3"`}, want: true},
+		{name: "strong form of the tag", headers: map[string]string{"If-None-Match": `"ns-3"`}, want: true},
+		{name: "any", headers: map[string]string{"If-None-Match": `*`}, want: true},
+		{name: "list", headers: map[string]string{"If-None-Match": `"ns-1", W/"ns-3"`}, want: true},
+		{name: "stale tag", headers: map[string]string{"If-None-Match": `W/"ns-2"`}, want: false},
+		{name: "HEAD", method: "HEAD", headers: map[string]string{"If-None-Match": `W/"ns-3"`}, want: true},
+		{name: "POST", method: "POST", headers: map[string]string{"If-None-Match": `*`}, want: false},
+		{name: "no ETag", headers: map[string]string{"If-None-Match": `*`}, h: http.Header{}, want: false},
+		{name: "unmodified since", headers: map[string]string{"If-Modified-Since": at(0)}, want: true},
+		{name: "unmodified since later", headers: map[string]string{"If-Modified-Since": at(time.Hour)}, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": at(-time.Second)}, want: false},
+		{name: "unparsable date", headers: map[string]string{"If-Modified-Since": "yesterday"}, want: false},
+		{name: "no Last-Modified", headers: map[string]string{"If-Modified-Since": at(0)}, h: http.Header{"Etag": {`"a"`}}, want: false},
+		{
+			name:    "If-None-Match overrides a matching If-Modified-Since",
+			headers: map[string]string{"If-None-Match": `W/"ns-2"`, "If-Modified-Since": at(time.Hour)},
+			want:    false,
+		},
+		{
+			name:    "If-None-Match overrides a failing If-Modified-Since",
+			headers: map[string]string{"If-None-Match": `W/"ns-3"`, "If-Modified-Since": at(-time.Hour)},
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		method := tt.method
+		if method == "" {
+			method = "GET"
+		}
+		r := httptest.NewRequest(method, "/top", nil)
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		h := tt.h
+		if h == nil {
+			h = validators
+		}
+		if got := notModified(r, h); got != tt.want {
+			t.Errorf("%s: notModified = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConditional(t *testing.T) {
+	versions := &testVersions{v: store.Version{Count: 3, Modified: time.Now()}}
+	next := &testHandler{}
+	next.set(http.StatusOK, "top", nil)
+	h := NewConditional(ConditionalConfig{Versions: versions, Namespace: "ns"})(next)
+
+	rec := get(h)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag != `W/"ns-3"` || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("got %d with ETag %q, Last-Modified %q", rec.Code, etag, rec.Header().Get("Last-Modified"))
+	}
+
+	r := httptest.NewRequest("GET", "/top", nil)
+	r.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("revalidation got %d %q with ETag %q", rec.Code, rec.Body, rec.Header().Get("ETag"))
+	}
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("handler ran %d times, want once", calls)
+	}
+
+	next.set(http.StatusInternalServerError, "boom", nil)
+	versions.write()
+	if rec := get(h); rec.Header().Get("ETag") != "" {
+		t.Errorf("an error response was tagged %q", rec.Header().Get("ETag"))
+	}
+}
+
+// etagHandler answers with a body, validators and a cache policy, as the
+// leaderboard routes do.
+var etagHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=5")
+	w.Header().Set("ETag", `"v1"`)
+	w.Write([]byte(`{"entries":[]}`))
+})
+
+func TestCacheNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  bool
+		inm    string
+		source string
+		status int
+	}{
+		{name: "miss", inm: `"v1"`, source: "MISS", status: http.StatusNotModified},
+		{name: "hit", inm: `"v1"`, source: "HIT-L2", status: http.StatusNotModified},
+		{name: "hit with a weak tag", inm: `W/"v1"`, source: "HIT-L2", status: http.StatusNotModified},
+		{name: "hit in process", local: true, inm: `"v1"`, source: "HIT-L1", status: http.StatusNotModified},
+		{name: "hit with a stale tag", inm: `"v0"`, source: "HIT-L2", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := CacheConfig{DefaultTTL: time.Minute, Tasks: &Tasks{}}
+			if tt.local {
+				config.Local = localcache.New(1<<20, time.Minute)
+			}
+			h := NewCache(newTestKV(t), config)(etagHandler)
+			if tt.source != "MISS" {
+				get(h)
+			}
+
+			r := httptest.NewRequest("GET", "/top", nil)
+			r.Header.Set("If-None-Match", tt.inm)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if got := rec.Header().Get(CacheStatusHeader); got != tt.source {
+				t.Errorf("X-Cache = %s, want %s", got, tt.source)
+			}
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != http.StatusNotModified {
+				return
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("304 with body %q", rec.Body)
+			}
+			for _, k := range []string{"Content-Type", "Content-Length"} {
+				if v := rec.Header().Get(k); v != "" {
+					t.Errorf("304 with %s %q", k, v)
+				}
+			}
+			for k, want := range map[string]string{"ETag": `"v1"`, "Cache-Control": "max-age=5"} {
+				if v := rec.Header().Get(k); v != want {
+					t.Errorf("304 with %s %q, want %q", k, v, want)
+				}
+			}
+
+			// The 304 must not have replaced the entry.
+			if rec := get(h); rec.Code != http.StatusOK || rec.Body.String() != `{"entries":[]}` {
+				t.Errorf("then got %d %q", rec.Code, rec.Body)
+			}
+		})
+	}
+}
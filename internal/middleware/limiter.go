@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-redis/internal/bans"
+	"go-redis/internal/clientip"
+	"go-redis/internal/keys"
+	"go-redis/internal/logging"
+	"go-redis/internal/metrics"
+	"go-redis/internal/problem"
+	"go-redis/internal/ratelimit"
+)
+
+// APIKeyHeader carries the client's API key.
+const APIKeyHeader = "X-API-Key"
+
+// Limiter decides requests against token buckets identified by key.
+// RateLimiter keeps them in process; RedisRateLimiter shares them between
+// replicas.
+type Limiter interface {
+	// Take spends one request from key's bucket, refilled at r. It returns
+	// false when the bucket cannot be tracked.
+	Take(ctx context.Context, key string, r ratelimit.Rate) (Decision, bool)
+	Stop()
+}
+
+// Decision is a limiter's verdict on one request against one limit.
+type Decision struct {
+	Allowed bool
+	// Policy names the policy the limit belongs to.
+	Policy string
+	// Name identifies the limit in the RateLimit headers: the policy name,
+	// followed by the limit's position when the policy has several.
+	Name string
+	Rate ratelimit.Rate
+	// Limit is the burst: how many requests a client with a full allowance
+	// may make at once.
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a denied client must wait for its next request.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the allowance is full again.
+	ResetAfter time.Duration
+}
+
+// stricter reports whether d should be reported in place of than: a
+// denial over an allowance, then the longer wait or the fewer requests
+// left.
+func (d Decision) stricter(than Decision) bool {
+	if d.Allowed != than.Allowed {
+		return !d.Allowed
+	}
+	if !d.Allowed {
+		return d.RetryAfter > than.RetryAfter
+	}
+	if d.Remaining != than.Remaining {
+		return d.Remaining < than.Remaining
+	}
+	return d.ResetAfter > than.ResetAfter
+}
+
+// tokenDecision builds the Decision for a bucket refilled at r holding
+// tokens after the request.
+func tokenDecision(allowed bool, r ratelimit.Rate, tokens float64) Decision {
+	per := float64(time.Second) / r.PerSecond()
+	d := Decision{
+		Allowed:    allowed,
+		Rate:       r,
+		Limit:      r.Burst,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		ResetAfter: time.Duration((float64(r.Burst) - tokens) * per),
+	}
+	if !allowed {
+		d.RetryAfter = time.Duration((1 - tokens) * per)
+	}
+	return d
+}
+
+// RateLimitConfig lists the policies NewRateLimit enforces.
+type RateLimitConfig struct {
+	Policies []ratelimit.Policy
+	APIKeys  map[string]ratelimit.APIKey
+	// Board is the leaderboard the routes serve.
+	Board string
+	// IPv6Prefix groups IPv6 clients for per-IP policies.
+	IPv6Prefix int
+	// LegacyHeaders also sends the strictest decision as X-RateLimit-*.
+	LegacyHeaders bool
+	// Bans, when set, records a violation whenever a limit other than a
+	// leaderboard-wide one denies a client, banning it under BanPolicy.
+	Bans      bans.Store
+	BanPolicy bans.Policy
+}
+
+// NewRateLimit checks each request against the limits of the policies
+// covering it, in configuration order, and rejects it with 429 at the first
+// one exhausted. The limits after it are not checked, so a denied request
+// spends nothing from them; those checked before it have already counted
+// it. The limits checked are reported in the RateLimit-Policy and RateLimit
+// headers.
+func NewRateLimit(limiter Limiter, config RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if rateLimitExempt(r.Context()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip, ok := getIP(r)
+			if !ok {
+				writeError(w, r, problem.New(http.StatusBadRequest, problem.CodeUnidentifiedClient, "the client address could not be determined"), "Unable to identify IP")
+				return
+			}
+
+			key, hasKey := config.APIKeys[r.Header.Get(APIKeyHeader)]
+			tier := ratelimit.Anonymous
+			if hasKey {
+				tier = key.Tier
+			}
+			_, route, _ := strings.Cut(r.Pattern, " ")
+			req := ratelimit.Request{Method: r.Method, Route: route, Tier: tier, Board: config.Board}
+
+			var decisions []Decision
+			worst := -1
+			strike := false
+		policies:
+			for i := range config.Policies {
+				p := &config.Policies[i]
+				if !p.Covers(req) {
+					continue
+				}
+				var id string
+				switch p.By {
+				case ratelimit.ByIP:
+					id = "ip:" + clientip.Group(ip, config.IPv6Prefix)
+				case ratelimit.ByKey:
+					if hasKey {
+						id = "key:" + apiKeyID(r)
+					}
+				case ratelimit.ByPlayer:
+					if key.Player != "" {
+						id = "player:" + key.Player
+					}
+				case ratelimit.ByBoard:
+					id = "board:" + config.Board
+				}
+				if id == "" {
+					continue
+				}
+				for j, rate := range p.Limits {
+					d, ok := limiter.Take(r.Context(), keys.RateLimit(p.Name, j, id), rate)
+					if !ok {
+						metrics.RateLimitDecisions.WithLabelValues("rejected", p.Name).Inc()
+						logging.SetRateLimit(r.Context(), "reject", p.Name)
+						writeError(w, r, problem.New(http.StatusServiceUnavailable, problem.CodeOverloaded, "too many clients are being tracked; try again later"), "Too many users, please try again later")
+						return
+					}
+					d.Policy = p.Name
+					d.Name = p.Name
+					if len(p.Limits) > 1 {
+						d.Name += "." + strconv.Itoa(j)
+					}
+					if !d.Allowed && p.By != ratelimit.ByBoard {
+						strike = true
+					}
+					if worst < 0 || d.stricter(decisions[worst]) {
+						worst = len(decisions)
+					}
+					decisions = append(decisions, d)
+					if !d.Allowed {
+						break policies
+					}
+				}
+			}
+			if worst < 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			policy := decisions[worst].Policy
+			writeLimitHeaders(w.Header(), decisions, decisions[worst], config.LegacyHeaders)
+			if !decisions[worst].Allowed {
+				metrics.RateLimitDecisions.WithLabelValues("denied", policy).Inc()
+				logging.SetRateLimit(r.Context(), "deny", policy)
+				if strike && config.Bans != nil {
+					ban(r.Context(), config, clientip.Group(ip, config.IPv6Prefix))
+				}
+				writeError(w, r, problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit "+decisions[worst].Name+" exceeded"), "Too Many Requests")
+				return
+			}
+
+			metrics.RateLimitDecisions.WithLabelValues("allowed", policy).Inc()
+			logging.SetRateLimit(r.Context(), "allow", policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ban records a violation by client, logging the ban it may lead to.
+func ban(ctx context.Context, config RateLimitConfig, client string) {
+	b, err := config.Bans.Strike(ctx, client, config.BanPolicy)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to record rate limit violation", "err", err)
+		return
+	}
+	if b != nil {
+		metrics.Bans.Inc()
+		slog.WarnContext(ctx, "Client banned", "client", client, "violations", b.Violations, "until", b.Expires)
+	}
+}
+
+// writeLimitHeaders describes every limit in RateLimit-Policy, as its
+// quota and the seconds it takes to refill, and RateLimit, as the requests
+// left and the seconds until the quota is full again. A denial adds
+// Retry-After. Legacy headers report the strictest decision alone, with
+// X-RateLimit-Reset as a Unix time.
+func writeLimitHeaders(h http.Header, decisions []Decision, worst Decision, legacy bool) {
+	policies := make([]string, len(decisions))
+	states := make([]string, len(decisions))
+	for i, d := range decisions {
+		policies[i] = fmt.Sprintf("%q;q=%d;w=%d", d.Name, d.Limit, d.Rate.Window())
+		states[i] = fmt.Sprintf("%q;r=%d;t=%d", d.Name, d.Remaining, ceilSeconds(d.ResetAfter))
+	}
+	h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+	h.Set("RateLimit", strings.Join(states, ", "))
+	if legacy {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(worst.Limit))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(worst.Remaining))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(ceilUnix(time.Now().Add(worst.ResetAfter)), 10))
+	}
+	if !worst.Allowed {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(worst.RetryAfter)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func ceilUnix(t time.Time) int64 {
+	if t.Nanosecond() > 0 {
+		return t.Unix() + 1
+	}
+	return t.Unix()
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-redis/internal/clientip"
+	"go-redis/internal/ratelimit"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	policies, err := ratelimit.ParsePolicies("name=fast limits=10/s; name=slow limits=3/m; name=pair limits=20/s,5/h:2; name=writes methods=POST limits=1/h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl := NewRateLimiter(time.Minute)
+	defer rl.Stop()
+	h := NewRateLimit(rl, RateLimitConfig{Policies: policies, LegacyHeaders: true})(&testHandler{status: http.StatusOK})
+
+	wantPolicy := `"fast";q=10;w=1, "slow";q=3;w=60, "pair.0";q=20;w=1, "pair.1";q=2;w=1440`
+	tests := []struct {
+		status int
+		// state lists the requests left under each limit.
+		state string
+		// legacyLimit and legacyRemaining describe the tightest limit.
+		legacyLimit, legacyRemaining int
+	}{
+		{http.StatusOK, `"fast";r=9;t=1, "slow";r=2;t=20, "pair.0";r=19;t=1, "pair.1";r=1;t=720`, 2, 1},
+		{http.StatusOK, `"fast";r=8;t=1, "slow";r=1;t=40, "pair.0";r=18;t=1, "pair.1";r=0;t=1440`, 2, 0},
+		// pair.1 denies the request after the others counted it.
+		{http.StatusTooManyRequests, `"fast";r=7;t=1, "slow";r=0;t=60, "pair.0";r=17;t=1, "pair.1";r=0;t=1440`, 2, 0},
+		// slow denies the request, so pair is not checked.
+		{http.StatusTooManyRequests, `"fast";r=6;t=1, "slow";r=0;t=60`, 3, 0},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", "/top", nil)
+		r = r.WithContext(clientip.NewContext(r.Context(), netip.MustParseAddr("192.0.2.1")))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		hdr := rec.Header()
+		if rec.Code != tt.status {
+			t.Errorf("request %d: status %d, want %d", i+1, rec.Code, tt.status)
+		}
+		// The last request checks only fast and slow; writes covers none.
+		if got := hdr.Get("RateLimit-Policy"); i < len(tests)-1 && got != wantPolicy {
+			t.Errorf("request %d: RateLimit-Policy = %s, want %s", i+1, got, wantPolicy)
+		}
+		if got := hdr.Get("RateLimit"); got != tt.state {
+			t.Errorf("request %d: RateLimit = %s, want %s", i+1, got, tt.state)
+		}
+		if got := hdr.Get("X-RateLimit-Limit"); got != strconv.Itoa(tt.legacyLimit) {
+			t.Errorf("request %d: X-RateLimit-Limit = %s, want %d", i+1, got, tt.legacyLimit)
+		}
+		if got := hdr.Get("X-RateLimit-Remaining"); got != strconv.Itoa(tt.legacyRemaining) {
+			t.Errorf("request %d: X-RateLimit-Remaining = %s, want %d", i+1, got, tt.legacyRemaining)
+		}
+		if retry := hdr.Get("Retry-After"); (retry != "") != (tt.status != http.StatusOK) {
+			t.Errorf("request %d: Retry-After = %q", i+1, retry)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go-redis/internal/problem"
+)
+
+// writeError refuses r with p: as a problem document on /v2 routes, and as
+// the plain text message on the original API, whose clients expect it.
+func writeError(w http.ResponseWriter, r *http.Request, p *problem.Details, message string) {
+	if strings.HasPrefix(r.URL.Path, "/v2/") {
+		problem.Send(w, r, p)
+		return
+	}
+	http.Error(w, message, p.Status)
+}
@@ -1,22 +1,19 @@
 package middleware
 
 import (
-	"log"
-	"net"
-	"net/http"
-	"strconv"
-	"strings"
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"go-redis/internal/ratelimit"
+
 	"golang.org/x/time/rate"
 )
 
+// RateLimiter keeps a token bucket per key in process.
 type RateLimiter struct {
 	visitors     *sync.Map
-	r            int // max requests per second
-	b            int // max burst size
 	maxVisitors  int32
 	currentCount int32
 	stopCleanup  chan struct{}
@@ -28,11 +25,9 @@ type visitor struct {
 	lastSeen atomic.Int64
 }
 
-func NewRateLimiter(r, b int, cleanupInterval time.Duration) *RateLimiter {
+func NewRateLimiter(cleanupInterval time.Duration) *RateLimiter {
 	rl := &RateLimiter{
 		visitors:     &sync.Map{},
-		r:            r,
-		b:            b,
 		maxVisitors:  0,
 		stopCleanup:  make(chan struct{}),
 		currentCount: 0,
@@ -42,90 +37,75 @@ func NewRateLimiter(r, b int, cleanupInterval time.Duration) *RateLimiter {
 	return rl
 }
 
+// Visitors returns the number of buckets being tracked.
+func (rl *RateLimiter) Visitors() int {
+	n := 0
+	rl.visitors.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
 func (rl *RateLimiter) Stop() {
 	rl.stopOnce.Do(func() {
 		close(rl.stopCleanup)
 	})
 }
 
-func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIP(r)
-		if ip == "" {
-			http.Error(w, "Unable to identify IP", http.StatusBadRequest)
-			return
-		}
-
-		log.Printf("[rate] incoming %s %s ip=%s", r.Method, r.URL.Path, ip)
-
-		limiter, remaining, resetTime := rl.getVisitor(ip)
-		if limiter == nil {
-			log.Printf("[rate] deny (too many visitors) ip=%s", ip)
-			http.Error(w, "Too many users, please try again later", http.StatusServiceUnavailable)
-			return
-		}
-
-		if !limiter.Allow() {
-			retryAfter := int(time.Until(resetTime).Seconds())
-			if retryAfter < 0 {
-				retryAfter = 1
-			}
-
-			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.r))
-			w.Header().Set("X-RateLimit-Remaining", "0")
-			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
+// Take spends a token from key's bucket. A bucket whose rate has changed,
+// after a reload, keeps its tokens and refills at the new rate. It returns
+// false when the bucket cannot be tracked because there are too many.
+func (rl *RateLimiter) Take(ctx context.Context, key string, r ratelimit.Rate) (Decision, bool) {
+	limiter := rl.getVisitor(key, r)
+	if limiter == nil {
+		return Decision{}, false
+	}
+	now := time.Now()
+	allowed := limiter.AllowN(now, 1)
+	return tokenDecision(allowed, r, limiter.TokensAt(now)), true
+}
 
-			// Log deny decision
-			log.Printf("[rate] deny 429 %s %s ip=%s rem=0 reset=%d retry=%d", r.Method, r.URL.Path, ip, resetTime.Unix(), retryAfter)
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
-			return
+func (rl *RateLimiter) getVisitor(key string, r ratelimit.Rate) *rate.Limiter {
+	now := time.Now()
+	limit := rate.Limit(r.PerSecond())
+	if val, ok := rl.visitors.Load(key); ok {
+		v := val.(*visitor)
+		v.lastSeen.Store(now.UnixNano())
+		if v.limiter.Limit() != limit || v.limiter.Burst() != r.Burst {
+			v.limiter.SetLimitAt(now, limit)
+			v.limiter.SetBurstAt(now, r.Burst)
 		}
+		return v.limiter
+	}
 
-		// Set rate limit headers
-		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.r))
-		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetTime.Unix(), 10))
-		next.ServeHTTP(w, r)
-	})
-}
-
-func (rl *RateLimiter) getVisitor(ip string) (*rate.Limiter, int, time.Time) {
-    now := time.Now()
-    resetTime := now.Truncate(time.Second).Add(time.Second)
-    if val, ok := rl.visitors.Load(ip); ok {
-        v := val.(*visitor)
-        v.lastSeen.Store(now.UnixNano())
-        remaining := int(v.limiter.Tokens())
-        return v.limiter, remaining, resetTime
-    }
-
-    // Create new visitor
-    limiter := rate.NewLimiter(rate.Limit(rl.r), rl.b)
-    v := &visitor{
-        limiter:  limiter,
+	// Create new visitor
+	limiter := rate.NewLimiter(limit, r.Burst)
+	v := &visitor{
+		limiter:  limiter,
 		lastSeen: atomic.Int64{},
 	}
 	v.lastSeen.Store(now.UnixNano())
 
-	if existing, loaded := rl.visitors.LoadOrStore(ip, v); loaded {
+	if existing, loaded := rl.visitors.LoadOrStore(key, v); loaded {
 		exV := existing.(*visitor)
 		exV.lastSeen.Store(now.UnixNano())
-		remaining := int(exV.limiter.Tokens())
-		return exV.limiter, remaining, resetTime
+		return exV.limiter
 	}
 
 	if rl.maxVisitors > 0 {
 		if atomic.AddInt32(&rl.currentCount, 1) > rl.maxVisitors {
-			rl.visitors.Delete(ip)
+			rl.visitors.Delete(key)
 			atomic.AddInt32(&rl.currentCount, -1)
-			return nil, 0, time.Time{}
+			return nil
 		}
 	}
 
-	return limiter, rl.b, resetTime
+	return limiter
 }
 
+// cleanupVisitors forgets buckets that are full again, which behave the
+// same as new ones, once they have been idle for a while.
 func (rl *RateLimiter) cleanupVisitors(interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -133,10 +113,12 @@ func (rl *RateLimiter) cleanupVisitors(interval time.Duration) {
 	for {
 		select {
 		case <-ticker.C:
+			now := time.Now()
 			rl.visitors.Range(func(key, value interface{}) bool {
 				v := value.(*visitor)
 				last := time.Unix(0, v.lastSeen.Load())
-				if time.Since(last) > 3*time.Minute {
+				full := v.limiter.TokensAt(now) >= float64(v.limiter.Burst())
+				if now.Sub(last) > 3*time.Minute && full {
 					rl.visitors.Delete(key)
 					if rl.maxVisitors > 0 {
 						atomic.AddInt32(&rl.currentCount, -1)
@@ -149,17 +131,3 @@ func (rl *RateLimiter) cleanupVisitors(interval time.Duration) {
 		}
 	}
 }
-
-func getIP(r *http.Request) string {
-	forwarded := r.Header.Get("X-Forwarded-For")
-	if forwarded != "" {
-		ips := strings.Split(forwarded, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return ""
-	}
-	return ip
-}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go-redis/internal/metrics"
+	"go-redis/internal/ratelimit"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fallbackCooldown is how long the local limiter stands in after Redis
+// fails, before Redis is tried again.
+const fallbackCooldown = time.Second
+
+// gcraScript implements the generic cell rate algorithm. The key holds the
+// theoretical arrival time (TAT) of the client's next request, in seconds
+// since gcraEpoch; a request is allowed when it arrives no earlier than
+// TAT - burst * interval. Time comes from Redis so replicas agree on it.
+//
+// KEYS[1] bucket key; ARGV[1] rate per second, ARGV[2] burst.
+// Returns {allowed, remaining, retry_after, reset_after}, durations in
+// seconds as strings to keep their fraction.
+var gcraScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local interval = 1 / rate
+
+local t = redis.call('TIME')
+local now = (tonumber(t[1]) - 1483228800) + tonumber(t[2]) / 1000000
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+  tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - burst * interval
+local diff = now - allow_at
+if diff < 0 then
+  return {0, 0, tostring(-diff), tostring(tat - now)}
+end
+
+local reset_after = new_tat - now
+redis.call('SET', KEYS[1], tostring(new_tat), 'PX', math.ceil(reset_after * 1000))
+return {1, math.floor(diff / interval), '0', tostring(reset_after)}
+`)
+
+// RedisRateLimiter keeps buckets in Redis, shared by all replicas, with a
+// GCRA script. While Redis is unavailable it falls back to a local
+// RateLimiter, so each replica then enforces the limits on its own.
+type RedisRateLimiter struct {
+	rdb      redis.UniversalClient
+	timeout  time.Duration
+	fallback *RateLimiter
+
+	// retryAt is when, in Unix nanoseconds, Redis is next tried after a
+	// failure.
+	retryAt atomic.Int64
+	failing atomic.Bool
+}
+
+// NewRedisRateLimiter bounds each Redis call by timeout. fallback serves
+// while Redis is unavailable and is stopped with the limiter.
+func NewRedisRateLimiter(rdb redis.UniversalClient, timeout time.Duration, fallback *RateLimiter) *RedisRateLimiter {
+	return &RedisRateLimiter{rdb: rdb, timeout: timeout, fallback: fallback}
+}
+
+func (rl *RedisRateLimiter) Stop() {
+	rl.fallback.Stop()
+}
+
+func (rl *RedisRateLimiter) Take(ctx context.Context, key string, r ratelimit.Rate) (Decision, bool) {
+	now := time.Now().UnixNano()
+	if now < rl.retryAt.Load() {
+		metrics.RateLimitFallbacks.Inc()
+		return rl.fallback.Take(ctx, key, r)
+	}
+
+	d, err := rl.takeRedis(ctx, key, r)
+	if err != nil {
+		rl.retryAt.Store(now + int64(fallbackCooldown))
+		if !rl.failing.Swap(true) {
+			slog.WarnContext(ctx, "Rate limiting locally, Redis unavailable", "err", err)
+		}
+		metrics.RateLimitFallbacks.Inc()
+		return rl.fallback.Take(ctx, key, r)
+	}
+	if rl.failing.Swap(false) {
+		slog.InfoContext(ctx, "Rate limiting through Redis again")
+	}
+	return d, true
+}
+
+func (rl *RedisRateLimiter) takeRedis(ctx context.Context, key string, r ratelimit.Rate) (Decision, error) {
+	ctx, cancel := context.WithTimeout(ctx, rl.timeout)
+	defer cancel()
+
+	res, err := gcraScript.Run(ctx, rl.rdb, []string{key}, r.PerSecond(), r.Burst).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	return parseGCRA(res, r)
+}
+
+// parseGCRA reads a gcraScript reply. Any other shape is an error, so the
+// caller falls back to the local limiter rather than guessing.
+func parseGCRA(res []interface{}, r ratelimit.Rate) (Decision, error) {
+	if len(res) != 4 {
+		return Decision{}, fmt.Errorf("ratelimit: GCRA script returned %d values", len(res))
+	}
+	allowed, ok1 := res[0].(int64)
+	remaining, ok2 := res[1].(int64)
+	retryStr, ok3 := res[2].(string)
+	resetStr, ok4 := res[3].(string)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return Decision{}, fmt.Errorf("ratelimit: unexpected GCRA script reply %v", res)
+	}
+	retryAfter, err := strconv.ParseFloat(retryStr, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("ratelimit: GCRA retry_after: %w", err)
+	}
+	resetAfter, err := strconv.ParseFloat(resetStr, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("ratelimit: GCRA reset_after: %w", err)
+	}
+	return Decision{
+		Allowed:    allowed == 1,
+		Rate:       r,
+		Limit:      r.Burst,
+		Remaining:  int(remaining),
+		RetryAfter: seconds(retryAfter),
+		ResetAfter: seconds(resetAfter),
+	}, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-redis/internal/ratelimit"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// benchRate allows every benchmark request, so both limiters do the same
+// work for each.
+var benchRate = ratelimit.Rate{Count: 1_000_000, Period: time.Second, Burst: 1_000_000}
+
+// benchKeys spreads requests over this many clients.
+const benchKeys = 1000
+
+func BenchmarkRateLimiterLocal(b *testing.B) {
+	rl := NewRateLimiter(time.Minute)
+	defer rl.Stop()
+	benchmarkLimiter(b, rl)
+}
+
+// BenchmarkRateLimiterRedis needs a Redis server at REDIS_ADDR.
+func BenchmarkRateLimiterRedis(b *testing.B) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		b.Skip("REDIS_ADDR not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	defer rdb.Close()
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		b.Skipf("Redis unavailable: %v", err)
+	}
+
+	local := NewRateLimiter(time.Minute)
+	rl := NewRedisRateLimiter(rdb, time.Second, local)
+	defer rl.Stop()
+	benchmarkLimiter(b, rl)
+	if rl.failing.Load() {
+		b.Fatal("fell back to the local limiter")
+	}
+}
+
+func benchmarkLimiter(b *testing.B, l Limiter) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "bench:" + strconv.Itoa(i)
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if d, ok := l.Take(ctx, keys[i%benchKeys], benchRate); !ok || !d.Allowed {
+				b.Error("request denied")
+				return
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+)
+
+// Tasks tracks work that outlives the request which started it, such as
+// cache writes, so shutdown can wait for it to finish.
+type Tasks struct {
+	wg sync.WaitGroup
+}
+
+// Go runs fn on a new goroutine. A nil Tasks runs it untracked.
+func (t *Tasks) Go(fn func()) {
+	if t == nil {
+		go fn()
+		return
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every task has finished or ctx is done.
+func (t *Tasks) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,8 +2,13 @@ package middleware
 
 import (
 	"context"
+	"maps"
 	"net/http"
+	"sync"
 	"time"
+
+	"go-redis/internal/metrics"
+	"go-redis/internal/problem"
 )
 
 type TimeoutConfig struct {
@@ -20,7 +25,7 @@ func NewTimeout(config TimeoutConfig) func(http.Handler) http.Handler {
 			ctx, cancel := context.WithTimeout(r.Context(), config.DefaultTimeout)
 			defer cancel()
 
-			rw := &responseWriter{ResponseWriter: w}
+			rw := &responseWriter{ResponseWriter: w, header: w.Header().Clone()}
 
 			done := make(chan struct{})
 
@@ -33,25 +38,61 @@ func NewTimeout(config TimeoutConfig) func(http.Handler) http.Handler {
 			case <-done:
 				return
 			case <-ctx.Done():
+				metrics.HTTPTimeouts.Inc()
+				// The handler may still be running; whatever it writes
+				// from now on is dropped.
+				rw.mu.Lock()
+				defer rw.mu.Unlock()
+				rw.timedOut = true
 				if !rw.written {
-					http.Error(w, "Request Timeout", http.StatusRequestTimeout)
+					writeError(w, r, problem.New(http.StatusRequestTimeout, problem.CodeTimeout, "the request took longer than "+config.DefaultTimeout.String()), "Request Timeout")
 				}
 			}
 		})
 	}
 }
 
+// responseWriter passes the handler's response through until the request
+// times out. The handler sets headers on a copy, so the timeout response
+// can be written while it is still running.
 type responseWriter struct {
 	http.ResponseWriter
-	written bool
+	header   http.Header
+	mu       sync.Mutex
+	written  bool
+	timedOut bool
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
-	rw.written = true
-	rw.ResponseWriter.WriteHeader(code)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.timedOut || rw.written {
+		return
+	}
+	rw.writeHeader(code)
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
-	rw.written = true
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !rw.written {
+		rw.writeHeader(http.StatusOK)
+	}
 	return rw.ResponseWriter.Write(b)
 }
+
+// writeHeader sends the handler's headers with code. Callers hold rw.mu.
+func (rw *responseWriter) writeHeader(code int) {
+	rw.written = true
+	dst := rw.ResponseWriter.Header()
+	clear(dst)
+	maps.Copy(dst, rw.header)
+	rw.ResponseWriter.WriteHeader(code)
+}
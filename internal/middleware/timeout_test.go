@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-redis/internal/logging"
+	"go-redis/internal/problem"
+)
+
+func TestTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	tests := []struct {
+		name   string
+		path   string
+		delay  time.Duration
+		status int
+		body   string
+	}{
+		{name: "in time", path: "/v2/leaderboard/top", status: http.StatusTeapot, body: "tea"},
+		{name: "too late", path: "/v2/leaderboard/top", delay: 5 * timeout, status: http.StatusRequestTimeout},
+		{name: "too late on v1", path: "/leaderboard/top", delay: 5 * timeout, status: http.StatusRequestTimeout, body: "Request Timeout\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(done)
+				time.Sleep(tt.delay)
+				// A late handler keeps setting what it decided while the
+				// log line and the timeout response are written.
+				logging.SetCache(r.Context(), "miss")
+				w.Header().Set("X-Handler", "yes")
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("tea"))
+			})
+			h := RequestLog(NewTimeout(TimeoutConfig{DefaultTimeout: timeout})(next))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			<-done
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			if late := tt.delay > 0; (rec.Header().Get("X-Handler") == "") != late {
+				t.Errorf("X-Handler = %q", rec.Header().Get("X-Handler"))
+			}
+			if rec.Header().Get(RequestIDHeader) == "" {
+				t.Error("the request ID was lost")
+			}
+			if tt.body != "" {
+				if rec.Body.String() != tt.body {
+					t.Errorf("body %q, want %q", rec.Body, tt.body)
+				}
+				return
+			}
+			var p problem.Details
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.Code != problem.CodeTimeout {
+				t.Errorf("got %q, want a timeout problem", rec.Body)
+			}
+		})
+	}
+}
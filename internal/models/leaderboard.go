@@ -1,12 +1,23 @@
 package models
 
 type ScoreRequest struct {
-    Player string `json:"player"`
-    Score  int    `json:"score"`
+	Player string `json:"player"`
+	Score  int    `json:"score"`
 }
 
 type LeaderboardEntry struct {
-    Rank   int     `json:"rank"`
-    Player string  `json:"player"`
-    Score  float64 `json:"score"`
+	Rank   int     `json:"rank"`
+	Player string  `json:"player"`
+	Score  float64 `json:"score"`
+	Tier   string  `json:"tier,omitempty"`
+}
+
+// TierInfo describes one tier and how many players it currently holds. Min
+// and Max are in the definition's mode (score or percentile); Max is absent
+// for the top tier.
+type TierInfo struct {
+	Name  string   `json:"name"`
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int64    `json:"count"`
 }
@@ -0,0 +1,114 @@
+package models
+
+// APIVersion is reported in the envelope of every v2 response.
+const APIVersion = "v2"
+
+// Envelope wraps the body of every successful v2 response.
+type Envelope struct {
+	APIVersion string      `json:"api_version"`
+	Data       interface{} `json:"data"`
+}
+
+type ScoreResponse struct {
+	Player           string  `json:"player"`
+	Score            float64 `json:"score"`
+	IdempotentReplay bool    `json:"idempotent_replay"`
+}
+
+type TopResponse struct {
+	Limit    int                `json:"limit"`
+	RankMode string             `json:"rank_mode"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+type PlayerRankResponse struct {
+	Player   string  `json:"player"`
+	Rank     int     `json:"rank"`
+	RankMode string  `json:"rank_mode"`
+	Score    float64 `json:"score"`
+	Total    int64   `json:"total"`
+	// Percentile is the share of the other players with a strictly lower
+	// score, 0-100; tied players share a percentile.
+	Percentile float64 `json:"percentile"`
+	Tier       string  `json:"tier,omitempty"`
+}
+
+type AroundResponse struct {
+	Player   string             `json:"player"`
+	Radius   int                `json:"radius"`
+	RankMode string             `json:"rank_mode"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+// PageResponse is one page of the full leaderboard. NextCursor and
+// PrevCursor are opaque and empty when there is no such page.
+type PageResponse struct {
+	Limit      int                `json:"limit"`
+	Offset     int64              `json:"offset"`
+	Total      int64              `json:"total"`
+	RankMode   string             `json:"rank_mode"`
+	Entries    []LeaderboardEntry `json:"entries"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	PrevCursor string             `json:"prev_cursor,omitempty"`
+}
+
+// ScoreRangeResponse lists players whose score lies within [Min, Max]. An
+// absent bound is unbounded. Count is the number of players in the range.
+type ScoreRangeResponse struct {
+	Min      *float64           `json:"min,omitempty"`
+	Max      *float64           `json:"max,omitempty"`
+	Count    int64              `json:"count"`
+	Offset   int64              `json:"offset"`
+	Limit    int                `json:"limit"`
+	RankMode string             `json:"rank_mode"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+// RankRangeResponse lists players ranked From through To inclusive. To is
+// capped at the number of ranked players; Count is the size of the range.
+type RankRangeResponse struct {
+	From     int64              `json:"from"`
+	To       int64              `json:"to"`
+	Count    int64              `json:"count"`
+	Offset   int64              `json:"offset"`
+	Limit    int                `json:"limit"`
+	RankMode string             `json:"rank_mode"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+type TiersResponse struct {
+	Mode  string     `json:"mode"`
+	Tiers []TierInfo `json:"tiers"`
+}
+
+type TierMembersResponse struct {
+	Tier     string             `json:"tier"`
+	Count    int64              `json:"count"`
+	Offset   int64              `json:"offset"`
+	Limit    int                `json:"limit"`
+	RankMode string             `json:"rank_mode"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
+
+// StatsResponse summarises the score distribution. Mean is exact when the
+// running totals maintained on submit cover every player and is otherwise
+// estimated from a random sample of SampleSize players.
+type StatsResponse struct {
+	Count       int64              `json:"count"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	MeanSampled bool               `json:"mean_sampled"`
+	SampleSize  int                `json:"sample_size,omitempty"`
+	Median      float64            `json:"median"`
+	Percentiles map[string]float64 `json:"percentiles"`
+	Histogram   []HistogramBucket  `json:"histogram"`
+}
+
+// HistogramBucket counts players scoring in [Min, Max); the last bucket
+// also includes Max.
+type HistogramBucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
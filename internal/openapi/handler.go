@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+//go:embed docs.html
+var docsHTML []byte
+
+// redocURL is the pinned Redoc bundle docs.html loads. When bumping it, update
+// docs.html too and give its script tag an integrity attribute computed with
+//
+//	curl -s $URL | openssl dgst -sha384 -binary | openssl base64 -A
+const redocURL = "https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"
+
+// docsPolicy lets the docs page run only the pinned bundle and the worker it
+// spawns, so neither a swapped CDN path nor injected markup can run scripts.
+const docsPolicy = "default-src 'self'; script-src " + redocURL + "; worker-src blob:; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src https://fonts.gstatic.com; " +
+	"img-src 'self' data:; frame-ancestors 'none'"
+
+// Handler serves the document as JSON. The document is encoded once, so it
+// must not be modified after Handler is called.
+func (d *Document) Handler() http.Handler {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic("openapi: encode document: " + err.Error())
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// DocsHandler serves the HTML documentation UI, which renders /openapi.json.
+func DocsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		w.Write(docsHTML)
+	})
+}
@@ -0,0 +1,287 @@
+// Package openapi builds an OpenAPI 3.1 document from the route table used to
+// register handlers, so the published spec cannot drift from the mux.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go-redis/internal/models"
+	"go-redis/internal/problem"
+)
+
+// Param describes a path, query or header parameter of an operation.
+type Param struct {
+	Name        string
+	In          string // "path", "query" or "header"
+	Description string
+	Type        string // JSON Schema type, defaults to "string"
+	Required    bool
+	Default     interface{}
+	Enum        []string
+	Minimum     *float64
+	Maximum     *float64
+}
+
+// conditionalParams are the request headers of Conditional operations.
+var conditionalParams = []Param{
+	{Name: "If-None-Match", In: "header", Description: "ETag of a copy already held; answered with 304 while it is current."},
+	{Name: "If-Modified-Since", In: "header", Description: "Answered with 304 when the leaderboard has not changed since; ignored with If-None-Match."},
+}
+
+// Operation documents a single method and path registered on the mux.
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	Params      []Param
+	// Request is a zero value of the JSON request body type, nil for none.
+	Request interface{}
+	// Response is a zero value of the success body type. A nil Response is
+	// documented as a free-form JSON object, or as plain text for Text routes.
+	Response interface{}
+	// Status is the success status, http.StatusOK when zero.
+	Status int
+	// Enveloped wraps Response in the v2 models.Envelope.
+	Enveloped bool
+	// Text marks routes answering with text/plain rather than JSON.
+	Text bool
+	// ContentType overrides the success media type.
+	ContentType string
+	// Problems lists error statuses answered with problem+json.
+	Problems []int
+	// Conditional routes send ETag and Last-Modified and answer
+	// If-None-Match and If-Modified-Since with 304 Not Modified.
+	Conditional bool
+	Deprecated  bool
+}
+
+// Info is the document's info object.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is the subset of an OpenAPI 3.1 document this package emits.
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*opObject `json:"paths"`
+	Components struct {
+		Schemas map[string]Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type opObject struct {
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []paramObject        `json:"parameters,omitempty"`
+	RequestBody *bodyObject          `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+type paramObject struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+type bodyObject struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// PathParams returns the wildcard names in a ServeMux path pattern.
+func PathParams(path string) []string {
+	var names []string
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// Build assembles the document for ops. Path parameters present in a pattern
+// but missing from Params are added automatically as required strings. Build
+// panics on duplicate operations or on path Params absent from the pattern,
+// since either means the route table and the spec disagree.
+func Build(info Info, ops []Operation) *Document {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]map[string]*opObject{},
+	}
+	g := newGenerator()
+
+	for _, op := range ops {
+		item, ok := doc.Paths[op.Path]
+		if !ok {
+			item = map[string]*opObject{}
+			doc.Paths[op.Path] = item
+		}
+		method := strings.ToLower(op.Method)
+		if _, dup := item[method]; dup {
+			panic("openapi: duplicate operation " + op.Method + " " + op.Path)
+		}
+		for _, p := range op.Params {
+			if p.In == "path" && !slices.Contains(PathParams(op.Path), p.Name) {
+				panic("openapi: " + op.Method + " " + op.Path + " has no path parameter " + p.Name)
+			}
+		}
+		item[method] = g.operation(op)
+	}
+
+	g.schema(problem.Details{})
+	doc.Components.Schemas = g.components
+	return doc
+}
+
+func (g *generator) operation(op Operation) *opObject {
+	o := &opObject{
+		Summary:     op.Summary,
+		Description: op.Description,
+		OperationID: operationID(op),
+		Tags:        op.Tags,
+		Responses:   map[string]*response{},
+		Deprecated:  op.Deprecated,
+	}
+
+	params := op.Params
+	if op.Conditional {
+		params = append(params[:len(params):len(params)], conditionalParams...)
+	}
+	declared := map[string]bool{}
+	for _, p := range params {
+		declared[p.In+":"+p.Name] = true
+		o.Parameters = append(o.Parameters, paramFor(p))
+	}
+	for _, name := range PathParams(op.Path) {
+		if !declared["path:"+name] {
+			o.Parameters = append(o.Parameters, paramFor(Param{Name: name, In: "path"}))
+		}
+	}
+
+	if op.Request != nil {
+		o.RequestBody = &bodyObject{
+			Required: true,
+			Content:  map[string]mediaType{"application/json": {Schema: g.schema(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	o.Responses[strconv.Itoa(status)] = g.success(op)
+	if op.Conditional {
+		o.Responses[strconv.Itoa(http.StatusNotModified)] = &response{
+			Description: http.StatusText(http.StatusNotModified),
+		}
+	}
+
+	for _, s := range op.Problems {
+		o.Responses[strconv.Itoa(s)] = &response{
+			Description: http.StatusText(s),
+			Content: map[string]mediaType{
+				problem.ContentType: {Schema: g.schema(problem.Details{})},
+			},
+		}
+	}
+	return o
+}
+
+func (g *generator) success(op Operation) *response {
+	r := &response{Description: http.StatusText(op.Status)}
+	if r.Description == "" {
+		r.Description = http.StatusText(http.StatusOK)
+	}
+
+	ct := op.ContentType
+	var s Schema
+	switch {
+	case op.Text:
+		if ct == "" {
+			ct = "text/plain"
+		}
+		s = Schema{"type": "string"}
+	case op.Response == nil:
+		s = Schema{"type": "object"}
+	default:
+		s = g.schema(op.Response)
+	}
+	if ct == "" {
+		ct = "application/json"
+	}
+	if op.Enveloped {
+		s = Schema{
+			"type": "object",
+			"properties": map[string]Schema{
+				"api_version": {"type": "string", "const": models.APIVersion},
+				"data":        s,
+			},
+			"required": []string{"api_version", "data"},
+		}
+	}
+	r.Content = map[string]mediaType{ct: {Schema: s}}
+	return r
+}
+
+func paramFor(p Param) paramObject {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	s := Schema{"type": typ}
+	if p.Default != nil {
+		s["default"] = p.Default
+	}
+	if len(p.Enum) > 0 {
+		s["enum"] = p.Enum
+	}
+	if p.Minimum != nil {
+		s["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		s["maximum"] = *p.Maximum
+	}
+	return paramObject{
+		Name:        p.Name,
+		In:          p.In,
+		Description: p.Description,
+		Required:    p.Required || p.In == "path",
+		Schema:      s,
+	}
+}
+
+// operationID derives a stable identifier such as "getV2LeaderboardTop".
+func operationID(op Operation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, seg := range strings.FieldsFunc(op.Path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '_' || r == '-'
+	}) {
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema (draft 2020-12) object.
+type Schema map[string]interface{}
+
+type generator struct {
+	components map[string]Schema
+}
+
+func newGenerator() *generator {
+	return &generator{components: map[string]Schema{}}
+}
+
+// schema returns the schema for v's type. Named struct types are added to the
+// components and referenced by $ref.
+func (g *generator) schema(v interface{}) Schema {
+	return g.typeSchema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *generator) typeSchema(t reflect.Type) Schema {
+	if t == nil {
+		return Schema{}
+	}
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		inner := g.typeSchema(t.Elem())
+		return Schema{"oneOf": []Schema{inner, {"type": "null"}}}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Interface:
+		return Schema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			g.components[name] = Schema{}
+			g.components[name] = g.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	return Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) Schema {
+	props := map[string]Schema{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, omitempty, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			if embedded, ok := g.typeSchema(derefType(f.Type)).resolve(g); ok {
+				for k, v := range embedded["properties"].(map[string]Schema) {
+					props[k] = v
+				}
+				if req, ok := embedded["required"].([]string); ok {
+					required = append(required, req...)
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.typeSchema(f.Type)
+		if !omitempty && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	s := Schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// resolve follows a component $ref.
+func (s Schema) resolve(g *generator) (Schema, bool) {
+	ref, ok := s["$ref"].(string)
+	if !ok {
+		_, isObj := s["properties"]
+		return s, isObj
+	}
+	c, ok := g.components[strings.TrimPrefix(ref, "#/components/schemas/")]
+	return c, ok
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func jsonName(f reflect.StructField) (name string, omitempty, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return parts[0], omitempty, false
+}
+
+// componentName names schemas after their Go type. Types outside the models
+// package are qualified with their package name, e.g. problem.Details becomes
+// "ProblemDetails".
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "" || pkg == "models" {
+		return t.Name()
+	}
+	r := []rune(pkg)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r) + t.Name()
+}
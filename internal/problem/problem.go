@@ -0,0 +1,72 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ContentType is the media type of RFC 7807 problem documents.
+const ContentType = "application/problem+json"
+
+// Machine-readable problem codes. Clients should switch on Code rather than
+// on Title or Detail, which are meant for humans and may change.
+const (
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInvalidBody      = "invalid_body"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeInvalidCursor    = "invalid_cursor"
+	CodePlayerRequired   = "player_required"
+	CodeInvalidScore     = "invalid_score"
+	CodePlayerNotFound   = "player_not_found"
+	CodeTierNotFound     = "tier_not_found"
+	CodeBanNotFound      = "ban_not_found"
+	CodeUnauthorized     = "unauthorized"
+	CodeInternal         = "internal_error"
+
+	// Codes of the problems the middleware answers with before a request
+	// reaches its handler.
+	CodeUnidentifiedClient = "unidentified_client"
+	CodeForbidden          = "forbidden"
+	CodeBanned             = "banned"
+	CodeRateLimited        = "rate_limited"
+	CodeOverloaded         = "overloaded"
+	CodeTimeout            = "timeout"
+)
+
+// Details is an RFC 7807 problem details body extended with a Code member.
+type Details struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Expires is when the ban ends, for CodeBanned problems.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// New builds a problem for status using the "about:blank" type, whose title
+// is by definition the HTTP status phrase.
+func New(status int, code, detail string) *Details {
+	return &Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write sends a problem document for r with the given status and code.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Send(w, r, New(status, code, detail))
+}
+
+// Send sends p as the problem document for r.
+func Send(w http.ResponseWriter, r *http.Request, p *Details) {
+	p.Instance = r.URL.Path
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,295 @@
+// Package ratelimit describes rate limit policies: which requests a policy
+// covers, what it counts them by and the limits it enforces.
+//
+// Policies are written one per ";"-separated entry as space-separated
+// field=value pairs, e.g.
+//
+//	name=writes methods=POST routes=/score,/v1/score,/v2/score by=player limits=5/s,100/h
+//
+// Fields:
+//
+//	name     required, unique letters, digits, ".", "_" or "-"; names the
+//	         policy in headers and logs
+//	by       what is counted: ip (default), key, player or board
+//	limits   required; comma-separated N/PERIOD[:BURST], PERIOD being s, m,
+//	         h, d or a duration such as 5m, BURST defaulting to N
+//	methods  HTTP methods covered; all when omitted
+//	routes   route paths covered, as registered ("/v2/leaderboard/around/{player}"),
+//	         a trailing * matching any suffix; all when omitted
+//	tiers    API key tiers covered, Anonymous for requests without a key;
+//	         all when omitted
+//	boards   leaderboards covered; all when omitted
+//
+// Every limit of every policy covering a request must allow it.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key is what a policy counts requests by.
+type Key string
+
+const (
+	ByIP Key = "ip"
+	// ByKey counts per API key; requests without a known key are skipped.
+	ByKey Key = "key"
+	// ByPlayer counts per player bound to the request's API key; requests
+	// without one are skipped.
+	ByPlayer Key = "player"
+	// ByBoard counts all requests to a leaderboard together.
+	ByBoard Key = "board"
+)
+
+// Anonymous is the tier of requests without a known API key.
+const Anonymous = "anonymous"
+
+// DefaultPolicy names the per-IP policy built from the rate_limit
+// requests_per_second and burst settings.
+const DefaultPolicy = "default"
+
+// Rate allows Count requests per Period, at most Burst at once.
+type Rate struct {
+	Count  int
+	Period time.Duration
+	Burst  int
+}
+
+// PerSecond is the sustained rate.
+func (r Rate) PerSecond() float64 {
+	return float64(r.Count) / r.Period.Seconds()
+}
+
+// Window is how long an empty allowance takes to refill, in whole seconds
+// and at least one.
+func (r Rate) Window() int {
+	d := time.Duration(r.Burst) * r.Period / time.Duration(r.Count)
+	return max(int((d+time.Second-1)/time.Second), 1)
+}
+
+func (r Rate) String() string {
+	s := strconv.Itoa(r.Count) + "/" + formatPeriod(r.Period)
+	if r.Burst != r.Count {
+		s += ":" + strconv.Itoa(r.Burst)
+	}
+	return s
+}
+
+// Policy is one parsed policy entry.
+type Policy struct {
+	Name    string
+	By      Key
+	Limits  []Rate
+	Methods []string
+	Routes  []string
+	Tiers   []string
+	Boards  []string
+}
+
+// Request is what policies are matched against.
+type Request struct {
+	Method string
+	// Route is the path the handler is registered under.
+	Route string
+	Tier  string
+	Board string
+}
+
+// Covers reports whether the policy applies to req.
+func (p *Policy) Covers(req Request) bool {
+	return matchAny(p.Methods, req.Method, false) &&
+		matchAny(p.Routes, req.Route, true) &&
+		matchAny(p.Tiers, req.Tier, false) &&
+		matchAny(p.Boards, req.Board, false)
+}
+
+func matchAny(patterns []string, v string, prefix bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p == v {
+			return true
+		}
+		if prefix && strings.HasSuffix(p, "*") && strings.HasPrefix(v, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Default returns the per-IP policy allowing rps requests per second in
+// bursts of burst.
+func Default(rps, burst int) Policy {
+	return Policy{
+		Name:   DefaultPolicy,
+		By:     ByIP,
+		Limits: []Rate{{Count: rps, Period: time.Second, Burst: burst}},
+	}
+}
+
+// ParsePolicies reads ";"-separated policies.
+func ParsePolicies(spec string) ([]Policy, error) {
+	var out []Policy
+	seen := map[string]bool{DefaultPolicy: true}
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		p, err := parsePolicy(entry)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("ratelimit: duplicate or reserved policy name %q", p.Name)
+		}
+		seen[p.Name] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func parsePolicy(entry string) (Policy, error) {
+	p := Policy{By: ByIP}
+	for _, field := range strings.Fields(entry) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return p, fmt.Errorf("ratelimit: %q: want field=value", field)
+		}
+		list := strings.Split(value, ",")
+		switch name {
+		case "name":
+			if !validName(value) {
+				return p, fmt.Errorf("ratelimit: %q: name may only hold letters, digits, \".\", \"_\" and \"-\"", field)
+			}
+			p.Name = value
+		case "by":
+			p.By = Key(value)
+			switch p.By {
+			case ByIP, ByKey, ByPlayer, ByBoard:
+			default:
+				return p, fmt.Errorf("ratelimit: %q: by must be ip, key, player or board", field)
+			}
+		case "limits":
+			for _, s := range list {
+				r, err := ParseRate(s)
+				if err != nil {
+					return p, err
+				}
+				p.Limits = append(p.Limits, r)
+			}
+		case "methods":
+			for _, m := range list {
+				p.Methods = append(p.Methods, strings.ToUpper(m))
+			}
+		case "routes":
+			p.Routes = list
+		case "tiers":
+			p.Tiers = list
+		case "boards":
+			p.Boards = list
+		default:
+			return p, fmt.Errorf("ratelimit: unknown field %q", name)
+		}
+	}
+	if p.Name == "" {
+		return p, fmt.Errorf("ratelimit: %q: missing name", strings.TrimSpace(entry))
+	}
+	if len(p.Limits) == 0 {
+		return p, fmt.Errorf("ratelimit: policy %s: missing limits", p.Name)
+	}
+	return p, nil
+}
+
+func validName(s string) bool {
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRate reads N/PERIOD[:BURST], e.g. "10/s", "1000/h" or "60/s:10".
+func ParseRate(s string) (Rate, error) {
+	spec, burst, hasBurst := strings.Cut(s, ":")
+	count, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("ratelimit: %q: want N/PERIOD[:BURST]", s)
+	}
+	r := Rate{}
+	var err error
+	if r.Count, err = strconv.Atoi(count); err != nil || r.Count < 1 {
+		return Rate{}, fmt.Errorf("ratelimit: %q: count must be a positive integer", s)
+	}
+	if r.Period, err = parsePeriod(period); err != nil {
+		return Rate{}, fmt.Errorf("ratelimit: %q: %v", s, err)
+	}
+	r.Burst = r.Count
+	if hasBurst {
+		if r.Burst, err = strconv.Atoi(burst); err != nil || r.Burst < 1 {
+			return Rate{}, fmt.Errorf("ratelimit: %q: burst must be a positive integer", s)
+		}
+	}
+	return r, nil
+}
+
+var periodUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+func parsePeriod(s string) (time.Duration, error) {
+	if d, ok := periodUnits[s]; ok {
+		return d, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period %q", s)
+	}
+	return d, nil
+}
+
+func formatPeriod(d time.Duration) string {
+	for _, unit := range []string{"d", "h", "m", "s"} {
+		if d == periodUnits[unit] {
+			return unit
+		}
+	}
+	return d.String()
+}
+
+// APIKey is a configured API key's tier and, optionally, the player it
+// authenticates.
+type APIKey struct {
+	Tier   string
+	Player string
+}
+
+// ParseAPIKeys reads KEY=TIER or KEY=TIER:PLAYER entries.
+func ParseAPIKeys(entries []string) (map[string]APIKey, error) {
+	out := make(map[string]APIKey, len(entries))
+	for i, e := range entries {
+		key, rest, ok := strings.Cut(e, "=")
+		tier, player, _ := strings.Cut(rest, ":")
+		// Entries hold secrets, so errors name them by position.
+		if !ok || key == "" || tier == "" {
+			return nil, fmt.Errorf("ratelimit: API key entry %d: want KEY=TIER[:PLAYER]", i+1)
+		}
+		if tier == Anonymous {
+			return nil, fmt.Errorf("ratelimit: API key entry %d: tier %q is reserved", i+1, Anonymous)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("ratelimit: API key entry %d: duplicate key", i+1)
+		}
+		out[key] = APIKey{Tier: tier, Player: player}
+	}
+	return out, nil
+}
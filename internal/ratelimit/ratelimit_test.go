@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePolicies(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Policy
+	}{
+		{"", nil},
+		{" ; ", nil},
+		{
+			"name=writes methods=post,Put routes=/score,/v2/* by=player limits=5/s,100/h:10",
+			[]Policy{{
+				Name:    "writes",
+				By:      ByPlayer,
+				Limits:  []Rate{{Count: 5, Period: time.Second, Burst: 5}, {Count: 100, Period: time.Hour, Burst: 10}},
+				Methods: []string{"POST", "PUT"},
+				Routes:  []string{"/score", "/v2/*"},
+			}},
+		},
+		{
+			"name=a limits=1/5m; name=b.2 by=board tiers=gold,anonymous boards=global limits=2/d",
+			[]Policy{
+				{Name: "a", By: ByIP, Limits: []Rate{{Count: 1, Period: 5 * time.Minute, Burst: 1}}},
+				{Name: "b.2", By: ByBoard, Limits: []Rate{{Count: 2, Period: 24 * time.Hour, Burst: 2}}, Tiers: []string{"gold", "anonymous"}, Boards: []string{"global"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParsePolicies(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePolicies(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePolicies(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParsePoliciesErrors(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"limits=1/s", "missing name"},
+		{"name=a", "missing limits"},
+		{"name=a limits", "want field=value"},
+		{"name=a limits=", "want field=value"},
+		{"name=a/b limits=1/s", "name may only hold"},
+		{"name=a by=host limits=1/s", "by must be"},
+		{"name=a limits=1/s color=red", "unknown field"},
+		{"name=a limits=1/s; name=a limits=2/s", "duplicate"},
+		{"name=default limits=1/s", "reserved"},
+		{"name=a limits=1", "want N/PERIOD"},
+		{"name=a limits=0/s", "count must be"},
+		{"name=a limits=x/s", "count must be"},
+		{"name=a limits=1/fortnight", "invalid period"},
+		{"name=a limits=1/-5m", "invalid period"},
+		{"name=a limits=1/s:0", "burst must be"},
+	}
+	for _, tt := range tests {
+		_, err := ParsePolicies(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParsePolicies(%q) = %v, want an error containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		spec   string
+		window int
+		str    string
+	}{
+		{"10/s", 1, "10/s"},
+		{"3/m", 60, "3/m"},
+		{"100/h:10", 360, "100/h:10"},
+		{"60/s:10", 1, "60/s:10"},
+		{"1/90s", 90, "1/1m30s"},
+		{"2/1h", 3600, "2/h"},
+	}
+	for _, tt := range tests {
+		r, err := ParseRate(tt.spec)
+		if err != nil {
+			t.Errorf("ParseRate(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := r.Window(); got != tt.window {
+			t.Errorf("%s: Window = %d, want %d", tt.spec, got, tt.window)
+		}
+		if got := r.String(); got != tt.str {
+			t.Errorf("%s: String = %q, want %q", tt.spec, got, tt.str)
+		}
+	}
+}
+
+func TestCovers(t *testing.T) {
+	p := Policy{
+		Methods: []string{"POST"},
+		Routes:  []string{"/score", "/v2/leaderboard/*"},
+		Tiers:   []string{"gold", Anonymous},
+		Boards:  []string{"global"},
+	}
+	covered := Request{Method: "POST", Route: "/score", Tier: "gold", Board: "global"}
+	tests := []struct {
+		name   string
+		policy Policy
+		req    func(Request) Request
+		want   bool
+	}{
+		{"every field matches", p, func(r Request) Request { return r }, true},
+		{"route prefix", p, func(r Request) Request { r.Route = "/v2/leaderboard/around/{player}"; return r }, true},
+		{"route without the wildcard is exact", p, func(r Request) Request { r.Route = "/score/extra"; return r }, false},
+		{"other method", p, func(r Request) Request { r.Method = "GET"; return r }, false},
+		{"anonymous tier", p, func(r Request) Request { r.Tier = Anonymous; return r }, true},
+		{"other tier", p, func(r Request) Request { r.Tier = "silver"; return r }, false},
+		{"other board", p, func(r Request) Request { r.Board = "weekly"; return r }, false},
+		{"no fields cover everything", Policy{}, func(r Request) Request { r.Method = "DELETE"; r.Route = "/anything"; return r }, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Covers(tt.req(covered)); got != tt.want {
+			t.Errorf("%s: Covers = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	got, err := ParseAPIKeys([]string{"k1=gold", "k2=silver:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]APIKey{"k1": {Tier: "gold"}, "k2": {Tier: "silver", Player: "alice"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAPIKeys = %+v, want %+v", got, want)
+	}
+	for _, entries := range [][]string{{"k1"}, {"=gold"}, {"k1="}, {"k1=anonymous"}, {"k1=gold", "k1=silver"}} {
+		if _, err := ParseAPIKeys(entries); err == nil {
+			t.Errorf("ParseAPIKeys(%q) succeeded", entries)
+		} else if strings.Contains(err.Error(), "k1") {
+			t.Errorf("ParseAPIKeys(%q): %v names the key", entries, err)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package routes
+
+import (
+	"go-redis/internal/bans"
+	"go-redis/internal/handlers"
+	"go-redis/internal/health"
+	"go-redis/internal/models"
+	"go-redis/internal/openapi"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// route pairs a handler with the OpenAPI description it is published under.
+// Every route served by SetupRoutes comes from this table, so /openapi.json
+// always matches the mux.
+type route struct {
+	openapi.Operation
+	handler http.HandlerFunc
+	// bare routes are registered without the CORS/timeout/rate-limit chain.
+	bare bool
+	// admin routes require the admin token instead of that chain.
+	admin bool
+	// cache says how responses are cached; not at all by default.
+	cache cachePolicy
+}
+
+// cachePolicy decides which writes invalidate a route's cached responses.
+type cachePolicy int
+
+const (
+	noCache cachePolicy = iota
+	// cacheBoard responses are drawn from the whole board, so any write
+	// invalidates them.
+	cacheBoard
+	// cachePlayer responses describe the player named by the "player" query
+	// parameter and only that player's writes invalidate them.
+	cachePlayer
+)
+
+func float(v float64) *float64 { return &v }
+
+var (
+	limitParam = openapi.Param{
+		Name: "limit", In: "query", Type: "integer", Default: 10,
+		Minimum: float(1), Maximum: float(100),
+		Description: "Number of entries; out-of-range values are clamped.",
+	}
+	radiusParam = openapi.Param{
+		Name: "radius", In: "query", Type: "integer", Default: 2,
+		Minimum: float(1), Maximum: float(10),
+		Description: "Entries on each side of the player; out-of-range values are clamped.",
+	}
+	pageLimitParam = openapi.Param{
+		Name: "limit", In: "query", Type: "integer", Default: 10,
+		Minimum: float(1), Maximum: float(100),
+		Description: "Page size; out-of-range values are clamped.",
+	}
+	cursorParam = openapi.Param{
+		Name: "cursor", In: "query",
+		Description: "Opaque cursor from next_cursor or prev_cursor. Cannot be combined with offset.",
+	}
+	offsetParam = openapi.Param{
+		Name: "offset", In: "query", Type: "integer", Default: 0, Minimum: float(0),
+		Description: "Zero-based position of the first entry.",
+	}
+	rangeOffsetParam = openapi.Param{
+		Name: "offset", In: "query", Type: "integer", Default: 0, Minimum: float(0),
+		Description: "Zero-based position within the range of the first entry.",
+	}
+	minScoreParam = openapi.Param{
+		Name: "min", In: "query", Type: "number",
+		Description: "Inclusive lower score bound; unbounded when absent.",
+	}
+	maxScoreParam = openapi.Param{
+		Name: "max", In: "query", Type: "number",
+		Description: "Inclusive upper score bound; unbounded when absent.",
+	}
+	fromRankParam = openapi.Param{
+		Name: "from", In: "query", Type: "integer", Default: 1, Minimum: float(1),
+		Description: "First rank of the range; values below 1 are clamped.",
+	}
+	toRankParam = openapi.Param{
+		Name: "to", In: "query", Type: "integer",
+		Description: "Last rank of the range; capped at the number of players.",
+	}
+	percentilesParam = openapi.Param{
+		Name: "percentiles", In: "query", Default: "25,50,75,90,95,99",
+		Description: "Comma-separated percentiles (0-100) to report.",
+	}
+	bucketsParam = openapi.Param{
+		Name: "buckets", In: "query", Type: "integer", Default: 10,
+		Minimum: float(1), Maximum: float(50),
+		Description: "Number of histogram buckets; out-of-range values are clamped.",
+	}
+	rankModeParam = openapi.Param{
+		Name: "rank_mode", In: "query", Default: "ordinal",
+		Enum: []string{"ordinal", "competition", "dense"},
+		Description: "How tied scores are ranked: ordinal (1234, ties ordered by player), " +
+			"competition (1224) or dense (1223).",
+	}
+	playerQueryParam = openapi.Param{
+		Name: "player", In: "query", Required: true,
+		Description: "Player identifier.",
+	}
+	idempotencyParam = openapi.Param{
+		Name: "Idempotency-Key", In: "header",
+		Description: "Replays within the idempotency window (two minutes by default) return the current score without adding to it.",
+	}
+	clientParam = openapi.Param{
+		Name: "client", In: "path", Required: true,
+		Description: "Client address, or a network as listed with its slash escaped as %2F; " +
+			"IPv6 addresses stand for their banned network.",
+	}
+)
+
+func v1Routes(prefix string, score *handlers.ScoreHandler, lb *handlers.LeaderboardHandler) []route {
+	tags := []string{"v1"}
+	deprecated := prefix == ""
+	return []route{
+		{Operation: openapi.Operation{
+			Method: "POST", Path: prefix + "/score", Summary: "Submit a score",
+			Tags: tags, Params: []openapi.Param{idempotencyParam},
+			Request: models.ScoreRequest{}, Status: http.StatusCreated, Deprecated: deprecated,
+		}, handler: score.SubmitScore},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: prefix + "/score", Summary: "Get a player's score",
+			Tags: tags, Params: []openapi.Param{playerQueryParam}, Deprecated: deprecated,
+		}, handler: score.GetScore, cache: cachePlayer},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: prefix + "/leaderboard/top", Summary: "Top players",
+			Tags: tags, Params: []openapi.Param{limitParam, rankModeParam},
+			Conditional: true, Deprecated: deprecated,
+		}, handler: lb.Top, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: prefix + "/leaderboard/player", Summary: "Player rank and percentile",
+			Tags: tags, Params: []openapi.Param{playerQueryParam, rankModeParam},
+			Conditional: true, Deprecated: deprecated,
+		}, handler: lb.Player, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: prefix + "/leaderboard/around/{player}", Summary: "Players ranked around a player",
+			Tags: tags, Params: []openapi.Param{radiusParam, rankModeParam},
+			Conditional: true, Deprecated: deprecated,
+		}, handler: lb.Around, cache: cacheBoard},
+	}
+}
+
+func v2Routes(score *handlers.ScoreHandler, lb *handlers.LeaderboardHandler) []route {
+	tags := []string{"v2"}
+	return []route{
+		{Operation: openapi.Operation{
+			Method: "POST", Path: "/v2/score", Summary: "Submit a score",
+			Description: "Adds score to the player's total. Idempotent replays answer 200 with the unchanged total.",
+			Tags:        tags, Params: []openapi.Param{idempotencyParam},
+			Request: models.ScoreRequest{}, Response: models.ScoreResponse{}, Status: http.StatusCreated,
+			Enveloped: true, Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: score.SubmitScoreV2},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/score", Summary: "Get a player's score",
+			Tags: tags, Params: []openapi.Param{playerQueryParam},
+			Response: models.ScoreResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}, handler: score.GetScoreV2, cache: cachePlayer},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/top", Summary: "Top players",
+			Tags: tags, Params: []openapi.Param{limitParam, rankModeParam},
+			Response: models.TopResponse{}, Enveloped: true, Conditional: true,
+			Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: lb.TopV2, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard", Summary: "Browse the full leaderboard",
+			Description: "Pages through every ranked player. Follow next_cursor/prev_cursor (also sent as " +
+				"Link headers) for stable paging while scores change, or pass offset to jump to a position.",
+			Tags: tags, Params: []openapi.Param{pageLimitParam, cursorParam, offsetParam, rankModeParam},
+			Response: models.PageResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: lb.Browse, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/scores", Summary: "Players within a score range",
+			Description: "Lists players scoring between min and max inclusive, highest first, with absolute ranks.",
+			Tags:        tags, Params: []openapi.Param{minScoreParam, maxScoreParam, rangeOffsetParam, pageLimitParam, rankModeParam},
+			Response: models.ScoreRangeResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: lb.ScoreRange, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/ranks", Summary: "Players within a rank range",
+			Description: "Lists players ranked from through to inclusive.",
+			Tags:        tags, Params: []openapi.Param{fromRankParam, toRankParam, rangeOffsetParam, pageLimitParam, rankModeParam},
+			Response: models.RankRangeResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: lb.RankRange, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/tiers", Summary: "Tier definitions and sizes",
+			Description: "tier.changed events are published for the submitting player only; in percentile " +
+				"mode other players a submission pushes across a boundary are not reported.",
+			Tags: tags, Response: models.TiersResponse{}, Enveloped: true,
+			Problems: []int{http.StatusInternalServerError},
+		}, handler: lb.Tiers, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/tiers/{tier}", Summary: "Players in a tier",
+			Tags: tags, Params: []openapi.Param{rangeOffsetParam, pageLimitParam, rankModeParam},
+			Response: models.TierMembersResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}, handler: lb.TierMembers, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/stats", Summary: "Score distribution statistics",
+			Description: "Count, min, max, mean, median, selected percentiles and a linear histogram. " +
+				"Percentiles use the nearest-rank method.",
+			Tags: tags, Params: []openapi.Param{percentilesParam, bucketsParam},
+			Response: models.StatsResponse{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, handler: lb.Stats, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/player", Summary: "Player rank and percentile",
+			Description: "percentile is the share of the other players with a strictly lower score (0-100), " +
+				"so tied players share it and a sole player is at 100.",
+			Tags: tags, Params: []openapi.Param{playerQueryParam, rankModeParam},
+			Response: models.PlayerRankResponse{}, Enveloped: true, Conditional: true,
+			Problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}, handler: lb.PlayerV2, cache: cacheBoard},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/v2/leaderboard/around/{player}", Summary: "Players ranked around a player",
+			Tags: tags, Params: []openapi.Param{radiusParam, rankModeParam},
+			Response: models.AroundResponse{}, Enveloped: true, Conditional: true,
+			Problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}, handler: lb.AroundV2, cache: cacheBoard},
+	}
+}
+
+func healthRoutes(h *handlers.HealthHandler) []route {
+	tags := []string{"ops"}
+	return []route{
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/health", Summary: "Health check", Tags: tags, Text: true,
+			Description: "Plain-text readiness: OK, or 503 when a dependency is down or the server is shutting down.",
+		}, handler: h.Health, bare: true},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/livez", Summary: "Liveness probe", Tags: tags, Text: true,
+			Description: "Answers while the process is serving; dependencies are not checked.",
+		}, handler: h.Live, bare: true},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/readyz", Summary: "Readiness probe",
+			Description: "Per-dependency status and timings. Answers 503 with the same report when the " +
+				"service should not receive traffic. Results are cached briefly.",
+			Tags: tags, Response: health.Report{},
+		}, handler: h.Ready, bare: true},
+	}
+}
+
+func adminRoutes(admin *handlers.AdminHandler) []route {
+	tags := []string{"admin"}
+	return []route{
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/admin/config", Summary: "Effective configuration",
+			Description: "The merged defaults, config file, environment and flags, with secrets redacted. " +
+				"Requires the admin token as a bearer token.",
+			Tags: tags, Response: map[string]interface{}{}, Enveloped: true,
+			Problems: []int{http.StatusUnauthorized},
+		}, handler: admin.Config, admin: true},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/admin/bans", Summary: "Banned clients",
+			Description: "Clients banned for repeated rate limit violations, with their expiry.",
+			Tags:        tags, Response: []bans.Ban{}, Enveloped: true,
+			Problems: []int{http.StatusUnauthorized},
+		}, handler: admin.Bans, admin: true},
+		{Operation: openapi.Operation{
+			Method: "GET", Path: "/admin/bans/{client}", Summary: "A client's ban",
+			Tags: tags, Params: []openapi.Param{clientParam}, Response: bans.Ban{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+		}, handler: admin.Ban, admin: true},
+		{Operation: openapi.Operation{
+			Method: "DELETE", Path: "/admin/bans/{client}", Summary: "Lift a ban",
+			Description: "Lifts the client's ban and returns it.",
+			Tags:        tags, Params: []openapi.Param{clientParam}, Response: bans.Ban{}, Enveloped: true,
+			Problems: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound},
+		}, handler: admin.LiftBan, admin: true},
+	}
+}
+
+// middlewareProblems are the statuses the access, rate limit and timeout
+// middleware may answer a /v2 route with before its handler runs.
+var middlewareProblems = []int{
+	http.StatusBadRequest, http.StatusForbidden, http.StatusRequestTimeout,
+	http.StatusTooManyRequests, http.StatusServiceUnavailable,
+}
+
+// applyMiddlewareProblems documents the problems of middlewareProblems on
+// the /v2 routes served behind that middleware.
+func applyMiddlewareProblems(table []route) {
+	for i := range table {
+		r := &table[i]
+		if r.bare || r.admin || !strings.HasPrefix(r.Path, "/v2/") {
+			continue
+		}
+		problems := slices.Clone(r.Problems)
+		for _, s := range middlewareProblems {
+			if !slices.Contains(problems, s) {
+				problems = append(problems, s)
+			}
+		}
+		slices.Sort(problems)
+		r.Problems = problems
+	}
+}
+
+// applyLimits documents the configured defaults and maxima of the limit
+// and radius parameters.
+func applyLimits(table []route, limits handlers.Limits) {
+	for i := range table {
+		params := make([]openapi.Param, len(table[i].Params))
+		copy(params, table[i].Params)
+		for j := range params {
+			p := &params[j]
+			switch p.Name {
+			case "limit":
+				p.Default, p.Maximum = limits.DefaultLimit, float(float64(limits.MaxLimit))
+			case "radius":
+				p.Default, p.Maximum = limits.DefaultRadius, float(float64(limits.MaxRadius))
+			}
+		}
+		table[i].Params = params
+	}
+}
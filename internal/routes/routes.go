@@ -1,45 +1,334 @@
 package routes
 
 import (
-    "net/http"
-    "time"
-    "go-redis/internal/handlers"
-    "go-redis/internal/middleware"
-    "github.com/redis/go-redis/v9"
-)
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go-redis/internal/bans"
+	"go-redis/internal/clientip"
+	"go-redis/internal/config"
+	"go-redis/internal/events"
+	"go-redis/internal/handlers"
+	"go-redis/internal/health"
+	"go-redis/internal/localcache"
+	"go-redis/internal/logging"
+	"go-redis/internal/metrics"
+	"go-redis/internal/middleware"
+	"go-redis/internal/openapi"
+	"go-redis/internal/ratelimit"
+	"go-redis/internal/store"
+	"go-redis/internal/tiers"
+	"go-redis/internal/tracing"
+	"log"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
 
-const (
-    defaultTimeout = 30 * time.Second
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-func SetupRoutes(redisClient *redis.Client) *http.ServeMux {
-    mux := http.NewServeMux()
-    scoreHandler := handlers.NewScoreHandler(redisClient)
-    leaderboardHandler := handlers.NewLeaderboardHandler(redisClient)
-
-    rateLimiter := middleware.NewRateLimiter(60, 10, 1*time.Minute)
-    
-    cors := middleware.NewCors(&middleware.CorsConfig{
-        AllowedOrigins: []string{"*"},
-        AllowedMethods: []string{"GET", "POST", "OPTIONS"},
-        AllowedHeaders: []string{"Content-Type", "Authorization"},
-    })
-    
-    timeout := middleware.NewTimeout(middleware.TimeoutConfig{
-        DefaultTimeout: defaultTimeout,
-    })
-
-    mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
-        w.WriteHeader(http.StatusOK)
-        w.Write([]byte("OK"))
-    })
-
-    mux.Handle("POST /score", cors(timeout(rateLimiter.Limit(http.HandlerFunc(scoreHandler.SubmitScore)))))
-    mux.Handle("GET /score", cors(timeout(rateLimiter.Limit(http.HandlerFunc(scoreHandler.GetScore)))))
-
-    mux.Handle("GET /leaderboard/top", cors(timeout(rateLimiter.Limit(http.HandlerFunc(leaderboardHandler.Top)))))
-    mux.Handle("GET /leaderboard/player", cors(timeout(rateLimiter.Limit(http.HandlerFunc(leaderboardHandler.Player)))))
-    mux.Handle("GET /leaderboard/around/{player}", cors(timeout(rateLimiter.Limit(http.HandlerFunc(leaderboardHandler.Around)))))
-
-    return mux
+// v1Prefixes lists the mount points of the original API. The unprefixed
+// paths are kept so clients predating /v1 keep working.
+var v1Prefixes = []string{"", "/v1"}
+
+var apiInfo = openapi.Info{
+	Title:   "GoRedis Leaderboard API",
+	Version: "2.0.0",
+	Description: "Redis-backed leaderboard. /v2 responses are wrapped in an envelope and " +
+		"errors are RFC 7807 problem documents; /v1 and unprefixed routes keep the original format. " +
+		"Besides their handlers' errors, /v2 routes may answer 403 (code forbidden, or banned with the " +
+		"ban's expires), 429 (rate_limited, with Retry-After), 503 (overloaded) and 408 (timeout).",
+}
+
+// Router serves the API from a mux built for the current configuration.
+// Reload builds a new mux and swaps it in; requests already being served
+// finish with the handlers and settings they started with.
+type Router struct {
+	store       store.LeaderboardStore
+	kv          store.KV
+	publisher   events.Publisher
+	rateLimiter middleware.Limiter
+	bans        bans.Store
+	health      *health.Checker
+	// tasks tracks work handlers leave running after responding.
+	tasks *middleware.Tasks
+	// local is the in-process cache layer, nil when disabled; stopWatch
+	// stops following the writes that invalidate it.
+	local     *localcache.Cache
+	stopWatch context.CancelFunc
+	mux       atomic.Pointer[http.ServeMux]
+}
+
+// Deps are the services the routes are built on. They are kept across
+// reloads.
+type Deps struct {
+	Store     store.LeaderboardStore
+	KV        store.KV
+	Publisher events.Publisher
+	Health    *health.Checker
+	// Limiter defaults to an in-process RateLimiter configured from cfg.
+	Limiter middleware.Limiter
+	// Bans defaults to a bans.MemoryStore.
+	Bans bans.Store
+}
+
+func SetupRoutes(cfg *config.Config, deps Deps) *Router {
+	rt := &Router{
+		store:       deps.Store,
+		kv:          deps.KV,
+		publisher:   deps.Publisher,
+		health:      deps.Health,
+		rateLimiter: deps.Limiter,
+		bans:        deps.Bans,
+		tasks:       &middleware.Tasks{},
+	}
+	if rt.bans == nil {
+		rt.bans = bans.NewMemoryStore()
+	}
+	if rt.rateLimiter == nil {
+		rt.rateLimiter = middleware.NewRateLimiter(cfg.RateLimit.CleanupInterval)
+	}
+	if local, ok := rt.rateLimiter.(*middleware.RateLimiter); ok {
+		metrics.ObserveVisitors(local.Visitors)
+	}
+	metrics.ObserveBoard(deps.Store)
+	if cfg.Cache.Local.Enabled {
+		rt.watchWrites(cfg.Cache.Local)
+	}
+	rt.mux.Store(rt.build(cfg))
+	return rt
+}
+
+// watchWrites sets up the local cache layer, dropping the entries each
+// write affects. Stores that do not announce writes get none.
+func (rt *Router) watchWrites(cfg config.LocalCacheConfig) {
+	watcher, ok := rt.store.(store.Watcher)
+	if !ok {
+		log.Printf("Local cache disabled; the store does not announce writes")
+		return
+	}
+	local := localcache.New(int64(cfg.MaxBytes), cfg.TTL)
+	ctx, cancel := context.WithCancel(context.Background())
+	go watcher.Watch(ctx, func(player string) {
+		if player == "" {
+			local.Purge()
+			return
+		}
+		local.Invalidate("", player)
+	})
+	rt.local, rt.stopWatch = local, cancel
+	metrics.ObserveLocalCache(local.Size)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.Load().ServeHTTP(w, r)
+}
+
+// Reload applies cfg to requests that arrive from now on. Client rate limit
+// state carries over; buckets whose limit changed refill at the new rate.
+func (rt *Router) Reload(cfg *config.Config) {
+	// Validated by config.Load.
+	logging.SetLevel(cfg.Logging.Level)
+	rt.mux.Store(rt.build(cfg))
+}
+
+// Drain makes /health and /readyz fail so load balancers stop sending
+// traffic while the server finishes the requests it has.
+func (rt *Router) Drain() {
+	rt.health.Drain()
+}
+
+// Shutdown waits for background work started by requests, then stops the
+// rate limiter and the local cache's invalidations. Call it once the HTTP server has stopped serving.
+func (rt *Router) Shutdown(ctx context.Context) error {
+	err := rt.tasks.Wait(ctx)
+	rt.rateLimiter.Stop()
+	if rt.stopWatch != nil {
+		rt.stopWatch()
+	}
+	return err
+}
+
+func (rt *Router) build(cfg *config.Config) *http.ServeMux {
+	mux := http.NewServeMux()
+	cors := tracing.Layer("cors", middleware.NewCors(&middleware.CorsConfig{
+		AllowedOrigins: cfg.CORS.AllowedOrigins,
+		AllowedMethods: cfg.CORS.AllowedMethods,
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+	}))
+
+	timeout := tracing.Layer("timeout", middleware.NewTimeout(middleware.TimeoutConfig{
+		DefaultTimeout: cfg.Server.RequestTimeout,
+	}))
+
+	// Bans are neither recorded nor enforced while disabled.
+	var banStore bans.Store
+	if cfg.Access.BanAfter > 0 {
+		banStore = rt.bans
+	}
+	access := tracing.Layer("access", middleware.NewAccess(middleware.AccessConfig{
+		Allow:      parsePrefixes(cfg.Access.Allow),
+		Deny:       parsePrefixes(cfg.Access.Deny),
+		Exempt:     parsePrefixes(cfg.Access.Exempt),
+		Bans:       banStore,
+		IPv6Prefix: cfg.ClientIP.IPv6Prefix,
+	}))
+
+	// Validated by config.Load.
+	apiKeys, _ := ratelimit.ParseAPIKeys(cfg.RateLimit.APIKeys)
+	policies, _ := ratelimit.ParsePolicies(cfg.RateLimit.Policies)
+	rateLimit := tracing.Layer("ratelimit", middleware.NewRateLimit(rt.rateLimiter, middleware.RateLimitConfig{
+		Policies:      append([]ratelimit.Policy{ratelimit.Default(cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)}, policies...),
+		APIKeys:       apiKeys,
+		Board:         rt.store.Board(),
+		IPv6Prefix:    cfg.ClientIP.IPv6Prefix,
+		LegacyHeaders: cfg.RateLimit.LegacyHeaders,
+		Bans:          banStore,
+		BanPolicy: bans.Policy{
+			Threshold: cfg.Access.BanAfter,
+			Window:    cfg.Access.BanWindow,
+			Duration:  cfg.Access.BanDuration,
+		},
+	}))
+
+	// Validated by config.Load.
+	resolver, _ := clientip.NewResolver(cfg.ClientIP.TrustedProxies, cfg.ClientIP.Header)
+	clientIP := middleware.NewClientIP(resolver)
+
+	caches := map[cachePolicy]func(http.Handler) http.Handler{}
+	if cfg.Cache.Enabled {
+		cacheConfig := middleware.CacheConfig{
+			SkipCacheHeader: cfg.Cache.BypassHeader,
+			CacheControl:    true,
+			Versions:        rt.store,
+			Namespace:       cacheNamespace(cfg),
+
+			StaleWhileRevalidate: cfg.Cache.StaleWhileRevalidate,
+			StaleAfterWrite:      cfg.Cache.StaleAfterWrite,
+			StaleIfError:         cfg.Cache.StaleIfError,
+			LockTimeout:          cfg.Cache.LockTimeout,
+			EarlyExpiration:      cfg.Cache.EarlyExpiration,
+			BuildTimeout:         cfg.Server.RequestTimeout,
+			Tasks:                rt.tasks,
+			Local:                rt.local,
+		}
+		board, player := cacheConfig, cacheConfig
+		board.DefaultTTL = cfg.Cache.TTL
+		player.DefaultTTL = cfg.Cache.PlayerTTL
+		player.PlayerParam = "player"
+		caches[cacheBoard] = tracing.Layer("cache", middleware.NewCache(rt.kv, board))
+		caches[cachePlayer] = tracing.Layer("cache", middleware.NewCache(rt.kv, player))
+	}
+
+	conditional := tracing.Layer("conditional", middleware.NewConditional(middleware.ConditionalConfig{
+		Versions:  rt.store,
+		Namespace: cacheNamespace(cfg),
+	}))
+
+	adminAuth := tracing.Layer("admin_auth", middleware.NewAdminAuth(cfg.Admin.Token))
+
+	table := rt.routes(cfg)
+	for _, r := range table {
+		pattern := r.Method + " " + r.Path
+		h := tracing.Handler(pattern, r.handler)
+		if r.Conditional {
+			h = conditional(h)
+		}
+		if cache, ok := caches[r.cache]; ok {
+			h = cache(h)
+		}
+		switch {
+		case r.admin:
+			h = adminAuth(h)
+		case !r.bare:
+			h = cors(timeout(access(rateLimit(h))))
+		}
+		// The server span takes its parent from the request's traceparent.
+		h = clientIP(middleware.RequestLog(metrics.Instrument(r.Path, h)))
+		mux.Handle(pattern, otelhttp.NewHandler(h, pattern))
+	}
+
+	return mux
+}
+
+// routes returns the table of routes served for cfg, with the handlers
+// built for it and the OpenAPI document describing them.
+func (rt *Router) routes(cfg *config.Config) []route {
+	// Validated by config.Load.
+	tierSet, _ := tiers.ParseSet(cfg.Leaderboard.Tiers)
+	var publisher events.Publisher
+	if cfg.Features.TierEvents {
+		publisher = rt.publisher
+	}
+	limits := handlers.Limits{
+		DefaultLimit:  cfg.Leaderboard.DefaultLimit,
+		MaxLimit:      cfg.Leaderboard.MaxLimit,
+		DefaultRadius: cfg.Leaderboard.DefaultRadius,
+		MaxRadius:     cfg.Leaderboard.MaxRadius,
+	}
+	scoreHandler := handlers.NewScoreHandler(rt.store, rt.kv, tierSet, publisher, cfg.Leaderboard.IdempotencyTTL)
+	leaderboardHandler := handlers.NewLeaderboardHandler(rt.store, tierSet, limits)
+	healthHandler := handlers.NewHealthHandler(rt.health)
+
+	table := healthRoutes(healthHandler)
+	for _, prefix := range v1Prefixes {
+		table = append(table, v1Routes(prefix, scoreHandler, leaderboardHandler)...)
+	}
+	table = append(table, v2Routes(scoreHandler, leaderboardHandler)...)
+	if cfg.Admin.Token != "" {
+		table = append(table, adminRoutes(handlers.NewAdminHandler(cfg, rt.bans))...)
+	} else {
+		log.Printf("Admin endpoints disabled; set ADMIN_TOKEN to enable them")
+	}
+	if cfg.Features.Metrics {
+		table = append(table, route{Operation: openapi.Operation{
+			Method: "GET", Path: "/metrics", Summary: "Prometheus metrics", Tags: []string{"ops"},
+			Text: true, Description: "Metrics in the Prometheus text exposition format.",
+		}, handler: metrics.Handler().ServeHTTP, bare: true})
+	}
+	applyLimits(table, limits)
+	applyMiddlewareProblems(table)
+
+	// The spec documents itself and the docs UI; its handler is bound once
+	// the document has been built from the complete table.
+	var specHandler http.Handler
+	if cfg.Features.Docs {
+		table = append(table,
+			route{Operation: openapi.Operation{
+				Method: "GET", Path: "/openapi.json", Summary: "OpenAPI document", Tags: []string{"ops"},
+			}, handler: func(w http.ResponseWriter, r *http.Request) {
+				specHandler.ServeHTTP(w, r)
+			}, bare: true},
+			route{Operation: openapi.Operation{
+				Method: "GET", Path: "/docs", Summary: "API documentation UI", Tags: []string{"ops"},
+				Text: true, ContentType: "text/html",
+			}, handler: openapi.DocsHandler().ServeHTTP, bare: true},
+		)
+	}
+
+	ops := make([]openapi.Operation, 0, len(table))
+	for _, r := range table {
+		ops = append(ops, r.Operation)
+	}
+	specHandler = openapi.Build(apiInfo, ops).Handler()
+	return table
+}
+
+// parsePrefixes reads CIDRs validated by config.Load.
+func parsePrefixes(list []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		p, _ := clientip.ParsePrefix(s)
+		prefixes = append(prefixes, p)
+	}
+	return prefixes
+}
+
+// cacheNamespace identifies the settings cached responses depend on, so
+// replicas sharing them share entries and ETags, and a reload changing them
+// starts afresh.
+func cacheNamespace(cfg *config.Config) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", cfg.Leaderboard)))
+	return hex.EncodeToString(sum[:4])
 }
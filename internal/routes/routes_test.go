@@ -0,0 +1,356 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-redis/internal/bans"
+	"go-redis/internal/config"
+	"go-redis/internal/events"
+	"go-redis/internal/health"
+	"go-redis/internal/problem"
+	"go-redis/internal/store"
+)
+
+const (
+	testAdminToken = "secret"
+	testPlayer     = "alice"
+	testTier       = "Silver"
+	testClient     = "203.0.113.7"
+)
+
+// newTestRouter serves the routes from a seeded memory store, with the
+// admin routes enabled and a banned client to look up. Each of configure
+// adjusts the configuration first.
+func newTestRouter(t *testing.T, configure ...func(*config.Config)) (*Router, *config.Config) {
+	t.Helper()
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.Admin.Token = testAdminToken
+	cfg.RateLimit.Burst = 1000
+	cfg.Leaderboard.Tiers = cfg.Store.Board + "=score:Bronze=0," + testTier + "=1000,Gold=5000"
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	lb := store.NewMemoryStore(cfg.Store.Board)
+	for player, score := range map[string]float64{"bob": 300, testPlayer: 1200, "carol": 5000, "dave": 1200} {
+		if _, err := lb.Increment(ctx, player, score); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs := bans.NewMemoryStore()
+	if _, err := bs.Strike(ctx, testClient, bans.Policy{Threshold: 1, Window: time.Minute, Duration: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	kv := store.NewMemoryKV(time.Minute)
+	t.Cleanup(kv.Stop)
+
+	rt := SetupRoutes(cfg, Deps{
+		Store:     lb,
+		KV:        kv,
+		Publisher: events.LogPublisher{},
+		Health:    health.NewChecker(time.Second, time.Second),
+		Bans:      bs,
+	})
+	t.Cleanup(func() { rt.Shutdown(context.Background()) })
+	return rt, cfg
+}
+
+// request builds a request that route answers successfully: path
+// parameters name seeded data and required parameters are filled in.
+func request(r route) *http.Request {
+	path := strings.NewReplacer("{player}", testPlayer, "{tier}", testTier, "{client}", testClient).Replace(r.Path)
+	query := make([]string, 0, len(r.Params))
+	for _, p := range r.Params {
+		if p.In == "query" && p.Required {
+			query = append(query, p.Name+"="+testPlayer)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + strings.Join(query, "&")
+	}
+	var body bytes.Buffer
+	if r.Request != nil {
+		fmt.Fprintf(&body, `{"player":%q,"score":10}`, testPlayer)
+	}
+	req := httptest.NewRequest(r.Method, path, &body)
+	if r.admin {
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	}
+	return req
+}
+
+// fetchSpec returns the document served at /openapi.json.
+func fetchSpec(t *testing.T, rt *Router) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: %d %s", rec.Code, rec.Body)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	return doc
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	rt, cfg := newTestRouter(t)
+	mux := rt.mux.Load()
+	paths := fetchSpec(t, rt)["paths"].(map[string]interface{})
+
+	table := rt.routes(cfg)
+	documented := 0
+	for _, item := range paths {
+		documented += len(item.(map[string]interface{}))
+	}
+	if documented != len(table) {
+		t.Errorf("spec documents %d operations, the mux serves %d", documented, len(table))
+	}
+	for _, r := range table {
+		pattern := r.Method + " " + r.Path
+		if _, got := mux.Handler(request(r)); got != pattern {
+			t.Errorf("%s: the mux routes it to %q", pattern, got)
+		}
+		item, _ := paths[r.Path].(map[string]interface{})
+		if _, ok := item[strings.ToLower(r.Method)]; !ok {
+			t.Errorf("%s: missing from the spec", pattern)
+		}
+	}
+}
+
+func TestResponsesMatchSpec(t *testing.T) {
+	rt, cfg := newTestRouter(t)
+	doc := fetchSpec(t, rt)
+	paths := doc["paths"].(map[string]interface{})
+
+	for _, r := range rt.routes(cfg) {
+		pattern := r.Method + " " + r.Path
+		t.Run(pattern, func(t *testing.T) {
+			op := paths[r.Path].(map[string]interface{})[strings.ToLower(r.Method)].(map[string]interface{})
+			status := r.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			resp, ok := op["responses"].(map[string]interface{})[strconv.Itoa(status)].(map[string]interface{})
+			if !ok {
+				t.Fatalf("no %d response documented", status)
+			}
+			content := resp["content"].(map[string]interface{})
+
+			rec := httptest.NewRecorder()
+			rt.ServeHTTP(rec, request(r))
+			if rec.Code != status {
+				t.Fatalf("status %d, documented %d: %s", rec.Code, status, rec.Body)
+			}
+			ct, _, _ := strings.Cut(rec.Header().Get("Content-Type"), ";")
+			media, ok := content[ct].(map[string]interface{})
+			if !ok {
+				t.Fatalf("Content-Type %q is not documented", ct)
+			}
+			if ct != "application/json" {
+				return
+			}
+			var body interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding the body: %v", err)
+			}
+			for _, err := range validate(doc, media["schema"].(map[string]interface{}), body, "body") {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestMiddlewareProblems(t *testing.T) {
+	rt, _ := newTestRouter(t, func(cfg *config.Config) {
+		cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst = 1, 1
+		cfg.Access.Deny = []string{"198.51.100.0/24"}
+		cfg.Access.BanAfter = 10
+	})
+	tests := []struct {
+		name, path, client string
+		status             int
+		code               string
+	}{
+		{"allowed", "/v2/leaderboard/top", "192.0.2.1", http.StatusOK, ""},
+		{"rate limited", "/v2/leaderboard/top", "192.0.2.1", http.StatusTooManyRequests, problem.CodeRateLimited},
+		{"rate limited v1", "/v1/leaderboard/top", "192.0.2.1", http.StatusTooManyRequests, ""},
+		{"denied", "/v2/leaderboard/top", "198.51.100.7", http.StatusForbidden, problem.CodeForbidden},
+		{"denied v1", "/leaderboard/top", "198.51.100.7", http.StatusForbidden, ""},
+		{"banned", "/v2/leaderboard/top", testClient, http.StatusForbidden, problem.CodeBanned},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.RemoteAddr = tt.client + ":1234"
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+			continue
+		}
+		ct := rec.Header().Get("Content-Type")
+		if tt.code == "" {
+			if ct == problem.ContentType {
+				t.Errorf("%s: got a problem document outside /v2", tt.name)
+			}
+			continue
+		}
+		var p problem.Details
+		if ct != problem.ContentType || json.Unmarshal(rec.Body.Bytes(), &p) != nil {
+			t.Errorf("%s: got %s %q, want a problem document", tt.name, ct, rec.Body)
+			continue
+		}
+		if p.Code != tt.code || p.Status != tt.status || p.Instance != tt.path {
+			t.Errorf("%s: got %+v, want code %s", tt.name, p, tt.code)
+		}
+		if (tt.code == problem.CodeBanned) != (p.Expires != nil) {
+			t.Errorf("%s: expires = %v", tt.name, p.Expires)
+		}
+	}
+}
+
+func TestLiftBan(t *testing.T) {
+	rt, _ := newTestRouter(t, func(cfg *config.Config) {
+		cfg.Access.BanAfter = 10
+	})
+	tests := []struct {
+		name, method, path string
+		admin              bool
+		status             int
+	}{
+		{"banned", "GET", "/v2/leaderboard/top", false, http.StatusForbidden},
+		{"listed", "GET", "/admin/bans/" + testClient, true, http.StatusOK},
+		{"unauthenticated lift", "DELETE", "/admin/bans/" + testClient, false, http.StatusUnauthorized},
+		{"lift", "DELETE", "/admin/bans/" + testClient, true, http.StatusOK},
+		{"served once lifted", "GET", "/v2/leaderboard/top", false, http.StatusOK},
+		{"no longer listed", "GET", "/admin/bans/" + testClient, true, http.StatusNotFound},
+		{"lifted twice", "DELETE", "/admin/bans/" + testClient, true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.RemoteAddr = testClient + ":1234"
+		if tt.admin {
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+		}
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: %s %s: status %d, want %d: %s", tt.name, tt.method, tt.path, rec.Code, tt.status, rec.Body)
+		}
+	}
+}
+
+// validate checks v against the subset of JSON Schema the openapi package
+// emits, resolving $ref against doc's components. Properties a schema does
+// not list are reported, since they would be undocumented.
+func validate(doc, s map[string]interface{}, v interface{}, at string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		resolved, ok := schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+		if !ok {
+			return []string{at + ": unresolved " + ref}
+		}
+		return validate(doc, resolved, v, at)
+	}
+	if alts, ok := s["oneOf"].([]interface{}); ok {
+		var errs []string
+		for _, alt := range alts {
+			altErrs := validate(doc, alt.(map[string]interface{}), v, at)
+			if len(altErrs) == 0 {
+				return nil
+			}
+			errs = append(errs, altErrs...)
+		}
+		return errs
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		return []string{fmt.Sprintf("%s: %v, want %v", at, v, c)}
+	}
+
+	typ, _ := s["type"].(string)
+	switch typ {
+	case "":
+		return nil
+	case "null":
+		if v != nil {
+			return []string{fmt.Sprintf("%s: %v, want null", at, v)}
+		}
+		return nil
+	case "boolean", "string", "number", "integer", "array", "object":
+	default:
+		return []string{at + ": unknown schema type " + typ}
+	}
+	if got := jsonType(v); got != typ && !(typ == "number" && got == "integer") {
+		return []string{fmt.Sprintf("%s: %s, want %s", at, got, typ)}
+	}
+
+	var errs []string
+	switch v := v.(type) {
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, validate(doc, items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[name.(string)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s: missing required %s", at, name))
+				}
+			}
+		}
+		extra, _ := s["additionalProperties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			switch prop, ok := props[k].(map[string]interface{}); {
+			case ok:
+				errs = append(errs, validate(doc, prop, v[k], at+"."+k)...)
+			case extra != nil:
+				errs = append(errs, validate(doc, extra, v[k], at+"."+k)...)
+			case props != nil:
+				errs = append(errs, fmt.Sprintf("%s: undocumented property %s", at, k))
+			}
+		}
+	}
+	return errs
+}
+
+// jsonType names the JSON Schema type of a value decoded by encoding/json.
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
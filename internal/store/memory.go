@@ -0,0 +1,375 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps a leaderboard in process. It suits single-instance
+// deployments and development without Redis; data is lost on restart.
+type MemoryStore struct {
+	board string
+
+	mu       sync.RWMutex
+	scores   map[string]float64
+	list     *skiplist
+	distinct *skiplist
+	counts   map[float64]int64
+	sum      float64
+	// versions tracks changing writes, under "" for the whole board and
+	// under each player on it.
+	versions map[string]Version
+	watchers map[*func(string)]struct{}
+}
+
+func NewMemoryStore(board string) *MemoryStore {
+	return &MemoryStore{
+		board:    board,
+		scores:   make(map[string]float64),
+		list:     newSkiplist(),
+		distinct: newSkiplist(),
+		counts:   make(map[float64]int64),
+		versions: make(map[string]Version),
+		watchers: make(map[*func(string)]struct{}),
+	}
+}
+
+func (s *MemoryStore) Board() string { return s.board }
+
+func (s *MemoryStore) Increment(ctx context.Context, player string, delta float64) (Update, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.scores[player]
+	return s.set(player, old+delta), nil
+}
+
+func (s *MemoryStore) SetIfBetter(ctx context.Context, player string, score float64) (Update, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.scores[player]; ok && old >= score {
+		below := s.below(old)
+		total := int64(len(s.scores))
+		return Update{Score: old, OldScore: old, Existed: true, OldBelow: below, NewBelow: below, OldTotal: total, NewTotal: total}, nil
+	}
+	return s.set(player, score), nil
+}
+
+func (s *MemoryStore) Remove(ctx context.Context, player string) (Update, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := Update{OldTotal: int64(len(s.scores))}
+	old, ok := s.scores[player]
+	if !ok {
+		u.NewTotal = u.OldTotal
+		return u, nil
+	}
+	u.OldScore, u.Existed, u.Changed = old, true, true
+	u.OldBelow = s.below(old)
+	s.unlink(player, old)
+	delete(s.scores, player)
+	s.sum -= old
+	s.bump(player)
+	u.NewTotal = int64(len(s.scores))
+	return u, nil
+}
+
+// set stores score for player and returns the resulting update. The caller
+// holds the write lock.
+func (s *MemoryStore) set(player string, score float64) Update {
+	u := Update{Score: score, Changed: true, OldTotal: int64(len(s.scores))}
+	if old, ok := s.scores[player]; ok {
+		u.OldScore, u.Existed = old, true
+		u.OldBelow = s.below(old)
+		s.unlink(player, old)
+		s.sum -= old
+	}
+	s.scores[player] = score
+	s.list.insert(score, player)
+	if s.counts[score]++; s.counts[score] == 1 {
+		s.distinct.insert(score, "")
+	}
+	s.sum += score
+	s.bump(player)
+	u.NewBelow = s.below(score)
+	u.NewTotal = int64(len(s.scores))
+	return u
+}
+
+// bump advances the board's version, gives it to player unless the player
+// was removed, and tells the watchers. The caller holds the write lock.
+func (s *MemoryStore) bump(player string) {
+	board := Version{Count: s.versions[""].Count + 1, Modified: time.Now()}
+	s.versions[""] = board
+	if _, ok := s.scores[player]; ok {
+		s.versions[player] = board
+	} else {
+		delete(s.versions, player)
+	}
+	for fn := range s.watchers {
+		(*fn)(player)
+	}
+}
+
+func (s *MemoryStore) Version(ctx context.Context, player string) (Version, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.versions[player], nil
+}
+
+func (s *MemoryStore) Watch(ctx context.Context, fn func(player string)) {
+	s.mu.Lock()
+	s.watchers[&fn] = struct{}{}
+	s.mu.Unlock()
+	<-ctx.Done()
+	s.mu.Lock()
+	delete(s.watchers, &fn)
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) unlink(player string, score float64) {
+	s.list.remove(score, player)
+	if s.counts[score]--; s.counts[score] <= 0 {
+		delete(s.counts, score)
+		s.distinct.remove(score, "")
+	}
+}
+
+// below counts players scoring strictly less than score.
+func (s *MemoryStore) below(score float64) int64 {
+	return s.list.length - s.list.countWhile(func(v float64) bool { return v >= score })
+}
+
+func (s *MemoryStore) Score(ctx context.Context, player string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	score, ok := s.scores[player]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return score, nil
+}
+
+func (s *MemoryStore) Rank(ctx context.Context, player string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	score, ok := s.scores[player]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return s.list.rank(score, player), nil
+}
+
+func (s *MemoryStore) Card(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list.length, nil
+}
+
+func (s *MemoryStore) Count(ctx context.Context, r ScoreRange) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	start, end := s.span(r)
+	return end - start, nil
+}
+
+// span returns the positions [start, end) of the players scoring within r.
+func (s *MemoryStore) span(r ScoreRange) (start, end int64) {
+	start = s.list.countWhile(func(v float64) bool {
+		return v > r.Max.Value || (r.Max.Exclusive && v == r.Max.Value)
+	})
+	end = s.list.countWhile(func(v float64) bool {
+		return v > r.Min.Value || (!r.Min.Exclusive && v == r.Min.Value)
+	})
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+func (s *MemoryStore) DistinctAbove(ctx context.Context, score float64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.distinct.countWhile(func(v float64) bool { return v > score }), nil
+}
+
+func (s *MemoryStore) RangeByRank(ctx context.Context, start, stop int64) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := s.list.length
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []Member{}, nil
+	}
+	return s.collect(start, stop-start+1), nil
+}
+
+func (s *MemoryStore) RangeByScore(ctx context.Context, r ScoreRange, offset, count int64) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	start, end := s.span(r)
+	if offset > 0 {
+		start += offset
+	}
+	if start >= end {
+		return []Member{}, nil
+	}
+	if count < 0 || start+count > end {
+		count = end - start
+	}
+	return s.collect(start, count), nil
+}
+
+// collect returns count players starting at position start. The caller
+// holds the lock and ensures the positions exist.
+func (s *MemoryStore) collect(start, count int64) []Member {
+	members := make([]Member, 0, count)
+	for x := s.list.at(start); x != nil && int64(len(members)) < count; x = x.next() {
+		members = append(members, Member{Player: x.player, Score: x.score})
+	}
+	return members
+}
+
+func (s *MemoryStore) Sample(ctx context.Context, n int) ([]Member, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	total := s.list.length
+	if int64(n) >= total {
+		return s.collect(0, total), nil
+	}
+	members := make([]Member, 0, n)
+	picked := make(map[int64]bool, n)
+	for len(members) < n {
+		pos := rand.Int64N(total)
+		if picked[pos] {
+			continue
+		}
+		picked[pos] = true
+		x := s.list.at(pos)
+		members = append(members, Member{Player: x.player, Score: x.score})
+	}
+	return members, nil
+}
+
+func (s *MemoryStore) Sum(ctx context.Context) (float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sum, true, nil
+}
+
+// MemoryKV implements KV with a map. Expired keys are dropped on access and
+// by a periodic sweep.
+type MemoryKV struct {
+	mu      sync.Mutex
+	entries map[string]kvEntry
+	stop    chan struct{}
+}
+
+type kvEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e kvEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func NewMemoryKV(cleanupInterval time.Duration) *MemoryKV {
+	kv := &MemoryKV{
+		entries: make(map[string]kvEntry),
+		stop:    make(chan struct{}),
+	}
+	go kv.cleanup(cleanupInterval)
+	return kv
+}
+
+func (kv *MemoryKV) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			kv.mu.Lock()
+			for k, e := range kv.entries {
+				if e.expired(now) {
+					delete(kv.entries, k)
+				}
+			}
+			kv.mu.Unlock()
+		case <-kv.stop:
+			return
+		}
+	}
+}
+
+// Stop ends the cleanup goroutine.
+func (kv *MemoryKV) Stop() {
+	close(kv.stop)
+}
+
+func (kv *MemoryKV) Get(ctx context.Context, key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	e, ok := kv.entries[key]
+	if !ok || e.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return e.value, nil
+}
+
+func (kv *MemoryKV) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.entries[key] = newEntry(value, ttl)
+	return nil
+}
+
+func (kv *MemoryKV) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if e, ok := kv.entries[key]; ok && !e.expired(time.Now()) {
+		return false, nil
+	}
+	kv.entries[key] = newEntry(value, ttl)
+	return true, nil
+}
+
+func (kv *MemoryKV) Delete(ctx context.Context, keys ...string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	for _, k := range keys {
+		delete(kv.entries, k)
+	}
+	return nil
+}
+
+func (kv *MemoryKV) DeleteIf(ctx context.Context, key string, value []byte) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if e, ok := kv.entries[key]; ok && !e.expired(time.Now()) && bytes.Equal(e.value, value) {
+		delete(kv.entries, key)
+	}
+	return nil
+}
+
+func newEntry(value []byte, ttl time.Duration) kvEntry {
+	e := kvEntry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	return e
+}
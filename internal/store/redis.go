@@ -0,0 +1,433 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go-redis/internal/keys"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// writeScript applies a write to the board KEYS[1] and keeps the derived
+// indexes in step: the running sum and member count in the stats hash
+// KEYS[2], the distinct score index KEYS[3] with its per-score player
+// counts KEYS[4], and the write counters and times in KEYS[5]. The first
+// three are only maintained once reindexScript has built them, which the
+// stats field "indexed" records; an empty board starts out indexed.
+// Changes are announced on the channel ARGV[5]. ARGV is the operation
+// ("incr", "best" or "del"), its value, the player, the time in Unix
+// milliseconds and that channel. It returns the new score ("" when removed), the old score
+// ("" for a new player), players strictly below the old and new scores, the
+// board size before and after, and 1 when the board changed.
+var writeScript = redis.NewScript(`
+local board, stats, distinct, counts, version = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local op, value, player, now, changes = ARGV[1], ARGV[2], ARGV[3], ARGV[4], ARGV[5]
+
+local old = redis.call('ZSCORE', board, player)
+local oldTotal = redis.call('ZCARD', board)
+local indexed = redis.call('HGET', stats, 'indexed') == '1'
+if not indexed and oldTotal == 0 then
+	redis.call('DEL', stats, distinct, counts)
+	redis.call('HSET', stats, 'indexed', 1)
+	indexed = true
+end
+local oldBelow = 0
+if old then
+	oldBelow = redis.call('ZCOUNT', board, '-inf', '(' .. old)
+end
+
+local new
+if op == 'incr' then
+	new = redis.call('ZINCRBY', board, value, player)
+elseif op == 'best' then
+	if old and tonumber(old) >= tonumber(value) then
+		return {old, old, oldBelow, oldBelow, oldTotal, oldTotal, 0}
+	end
+	redis.call('ZADD', board, value, player)
+	new = redis.call('ZSCORE', board, player)
+else
+	if not old then
+		return {'', '', 0, 0, oldTotal, oldTotal, 0}
+	end
+	redis.call('ZREM', board, player)
+end
+
+if indexed then
+	local delta = (new and tonumber(new) or 0) - (old and tonumber(old) or 0)
+	redis.call('HINCRBYFLOAT', stats, 'sum', string.format('%.17g', delta))
+	if old then
+		local held = tonumber(redis.call('HGET', counts, old) or '0')
+		if held > 1 then
+			redis.call('HINCRBY', counts, old, -1)
+		elseif held == 1 then
+			redis.call('HDEL', counts, old)
+			redis.call('ZREM', distinct, old)
+		end
+	end
+	if new then
+		if redis.call('HINCRBY', counts, new, 1) == 1 then
+			redis.call('ZADD', distinct, new, new)
+		end
+	end
+	if not old then
+		redis.call('HINCRBY', stats, 'count', 1)
+	elseif not new then
+		redis.call('HINCRBY', stats, 'count', -1)
+	end
+end
+
+local count = redis.call('HINCRBY', version, 'board', 1)
+if new then
+	redis.call('HSET', version, 'player:' .. player, count, 'modified:board', now, 'modified:player:' .. player, now)
+else
+	redis.call('HSET', version, 'modified:board', now)
+	redis.call('HDEL', version, 'player:' .. player, 'modified:player:' .. player)
+end
+redis.call('PUBLISH', changes, player)
+
+local newBelow = 0
+if new then
+	newBelow = redis.call('ZCOUNT', board, '-inf', '(' .. new)
+end
+return {new or '', old or '', oldBelow, newBelow, oldTotal, redis.call('ZCARD', board), 1}
+`)
+
+// reindexScript builds the stats, distinct and counts indexes of
+// writeScript from the board, for boards holding players written before
+// they were kept. It walks the whole board, but only once: it returns 0
+// without doing anything when the board is already indexed, and 1 after
+// building the indexes.
+var reindexScript = redis.NewScript(`
+local board, stats, distinct, counts = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+if redis.call('HGET', stats, 'indexed') == '1' then
+	return 0
+end
+redis.call('DEL', stats, distinct, counts)
+local sum, n, offset = 0, 0, 0
+while true do
+	local batch = redis.call('ZRANGE', board, offset, offset + 999, 'WITHSCORES')
+	if #batch == 0 then
+		break
+	end
+	for i = 2, #batch, 2 do
+		sum = sum + tonumber(batch[i])
+		n = n + 1
+		if redis.call('HINCRBY', counts, batch[i], 1) == 1 then
+			redis.call('ZADD', distinct, batch[i], batch[i])
+		end
+	end
+	offset = offset + 1000
+end
+redis.call('HSET', stats, 'sum', string.format('%.17g', sum), 'count', n, 'indexed', 1)
+return 1
+`)
+
+// RedisStore keeps a leaderboard in a sorted set, plus the indexes described
+// at writeScript. All keys share the board's hash tag.
+type RedisStore struct {
+	rdb            redis.UniversalClient
+	board          string
+	key            string
+	statsKey       string
+	distinctKey    string
+	distinctCounts string
+	versionKey     string
+	changesChannel string
+}
+
+func NewRedisStore(rdb redis.UniversalClient, board string) *RedisStore {
+	return &RedisStore{
+		rdb:            rdb,
+		board:          board,
+		key:            keys.Board(board),
+		statsKey:       keys.Stats(board),
+		distinctKey:    keys.Distinct(board),
+		distinctCounts: keys.DistinctCounts(board),
+		versionKey:     keys.Version(board),
+		changesChannel: keys.Changes(board),
+	}
+}
+
+func (s *RedisStore) Board() string { return s.board }
+
+func (s *RedisStore) Increment(ctx context.Context, player string, delta float64) (Update, error) {
+	return s.write(ctx, "incr", delta, player)
+}
+
+func (s *RedisStore) SetIfBetter(ctx context.Context, player string, score float64) (Update, error) {
+	return s.write(ctx, "best", score, player)
+}
+
+func (s *RedisStore) Remove(ctx context.Context, player string) (Update, error) {
+	return s.write(ctx, "del", 0, player)
+}
+
+func (s *RedisStore) write(ctx context.Context, op string, value float64, player string) (Update, error) {
+	keys := []string{s.key, s.statsKey, s.distinctKey, s.distinctCounts, s.versionKey}
+	vals, err := writeScript.Run(ctx, s.rdb, keys, op, formatFloat(value), player, time.Now().UnixMilli(), s.changesChannel).Slice()
+	if err != nil {
+		return Update{}, err
+	}
+	if len(vals) != 7 {
+		return Update{}, fmt.Errorf("store: write script returned %d values", len(vals))
+	}
+
+	var u Update
+	newStr, _ := vals[0].(string)
+	oldStr, _ := vals[1].(string)
+	if newStr != "" {
+		if u.Score, err = strconv.ParseFloat(newStr, 64); err != nil {
+			return Update{}, err
+		}
+	}
+	if oldStr != "" {
+		u.Existed = true
+		if u.OldScore, err = strconv.ParseFloat(oldStr, 64); err != nil {
+			return Update{}, err
+		}
+	}
+	u.OldBelow, _ = vals[2].(int64)
+	u.NewBelow, _ = vals[3].(int64)
+	u.OldTotal, _ = vals[4].(int64)
+	u.NewTotal, _ = vals[5].(int64)
+	changed, _ := vals[6].(int64)
+	u.Changed = changed == 1
+	return u, nil
+}
+
+func (s *RedisStore) Version(ctx context.Context, player string) (Version, error) {
+	field := "board"
+	if player != "" {
+		field = "player:" + player
+	}
+	vals, err := s.rdb.HMGet(ctx, s.versionKey, field, "modified:"+field).Result()
+	if err != nil {
+		return Version{}, err
+	}
+	var v Version
+	if count, ok := vals[0].(string); ok {
+		if v.Count, err = strconv.ParseInt(count, 10, 64); err != nil {
+			return Version{}, err
+		}
+	}
+	if ms, ok := vals[1].(string); ok {
+		n, err := strconv.ParseInt(ms, 10, 64)
+		if err != nil {
+			return Version{}, err
+		}
+		v.Modified = time.UnixMilli(n)
+	}
+	return v, nil
+}
+
+// Watch follows the changes writeScript publishes. It reports possibly
+// missed changes on subscribing, including after a reconnect.
+func (s *RedisStore) Watch(ctx context.Context, fn func(player string)) {
+	ps := s.rdb.Subscribe(ctx, s.changesChannel)
+	defer ps.Close()
+	ch := ps.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			switch m := msg.(type) {
+			case *redis.Subscription:
+				fn("")
+			case *redis.Message:
+				fn(m.Payload)
+			}
+		}
+	}
+}
+
+func (s *RedisStore) Score(ctx context.Context, player string) (float64, error) {
+	score, err := s.rdb.ZScore(ctx, s.key, player).Result()
+	if err == redis.Nil {
+		return 0, ErrNotFound
+	}
+	return score, err
+}
+
+func (s *RedisStore) Rank(ctx context.Context, player string) (int64, error) {
+	rank, err := s.rdb.ZRevRank(ctx, s.key, player).Result()
+	if err == redis.Nil {
+		return 0, ErrNotFound
+	}
+	return rank, err
+}
+
+func (s *RedisStore) Card(ctx context.Context) (int64, error) {
+	return s.rdb.ZCard(ctx, s.key).Result()
+}
+
+func (s *RedisStore) Count(ctx context.Context, r ScoreRange) (int64, error) {
+	return s.rdb.ZCount(ctx, s.key, formatBound(r.Min), formatBound(r.Max)).Result()
+}
+
+func (s *RedisStore) DistinctAbove(ctx context.Context, score float64) (int64, error) {
+	for attempt := 0; ; attempt++ {
+		pipe := s.rdb.Pipeline()
+		indexedCmd := pipe.HGet(ctx, s.statsKey, "indexed")
+		aboveCmd := pipe.ZCount(ctx, s.distinctKey, formatBound(Bound{Value: score, Exclusive: true}), "+inf")
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return 0, err
+		}
+		if indexedCmd.Val() == "1" {
+			return aboveCmd.Val(), nil
+		}
+		if attempt > 0 {
+			return 0, errNotIndexed
+		}
+		if _, err := s.Reindex(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// errNotIndexed reports indexes still missing after Reindex, as when reads
+// go to a replica that has yet to catch up.
+var errNotIndexed = errors.New("store: board indexes are not built")
+
+// Reindex builds the indexes behind DistinctAbove and Sum for a board
+// written before they were kept, and reports whether it had to. It blocks
+// Redis for a walk of the board the one time it does; run it at startup to
+// keep that out of requests, which otherwise trigger it on first use.
+func (s *RedisStore) Reindex(ctx context.Context) (bool, error) {
+	keys := []string{s.key, s.statsKey, s.distinctKey, s.distinctCounts}
+	n, err := reindexScript.Run(ctx, s.rdb, keys).Int64()
+	return n == 1, err
+}
+
+func (s *RedisStore) RangeByRank(ctx context.Context, start, stop int64) ([]Member, error) {
+	zs, err := s.rdb.ZRevRangeWithScores(ctx, s.key, start, stop).Result()
+	return toMembers(zs), err
+}
+
+func (s *RedisStore) RangeByScore(ctx context.Context, r ScoreRange, offset, count int64) ([]Member, error) {
+	zs, err := s.rdb.ZRevRangeByScoreWithScores(ctx, s.key, &redis.ZRangeBy{
+		Min:    formatBound(r.Min),
+		Max:    formatBound(r.Max),
+		Offset: offset,
+		Count:  count,
+	}).Result()
+	return toMembers(zs), err
+}
+
+func (s *RedisStore) Sample(ctx context.Context, n int) ([]Member, error) {
+	zs, err := s.rdb.ZRandMemberWithScores(ctx, s.key, n).Result()
+	return toMembers(zs), err
+}
+
+// Sum is exact when the running totals kept by writeScript account for
+// every player on the board.
+func (s *RedisStore) Sum(ctx context.Context) (float64, bool, error) {
+	for attempt := 0; ; attempt++ {
+		vals, err := s.rdb.HMGet(ctx, s.statsKey, "sum", "indexed").Result()
+		if err != nil {
+			return 0, false, err
+		}
+		if indexed, _ := vals[1].(string); indexed != "1" {
+			if attempt > 0 {
+				return 0, false, errNotIndexed
+			}
+			if _, err := s.Reindex(ctx); err != nil {
+				return 0, false, err
+			}
+			continue
+		}
+		sumStr, _ := vals[0].(string)
+		if sumStr == "" {
+			return 0, true, nil
+		}
+		sum, err := strconv.ParseFloat(sumStr, 64)
+		if err != nil {
+			return 0, false, nil
+		}
+		return sum, true, nil
+	}
+}
+
+func toMembers(zs []redis.Z) []Member {
+	members := make([]Member, 0, len(zs))
+	for _, z := range zs {
+		player, _ := z.Member.(string)
+		members = append(members, Member{Player: player, Score: z.Score})
+	}
+	return members
+}
+
+// formatBound renders a bound in the form ZCOUNT and ZRANGEBYSCORE accept.
+func formatBound(b Bound) string {
+	s := formatFloat(b.Value)
+	if b.Exclusive {
+		return "(" + s
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// deleteIfScript deletes KEYS[1] if it holds ARGV[1].
+var deleteIfScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisKV implements KV with plain Redis strings.
+type RedisKV struct {
+	rdb redis.UniversalClient
+}
+
+func NewRedisKV(rdb redis.UniversalClient) *RedisKV {
+	return &RedisKV{rdb: rdb}
+}
+
+func (kv *RedisKV) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := kv.rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+func (kv *RedisKV) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return kv.rdb.Set(ctx, key, value, ttl).Err()
+}
+
+func (kv *RedisKV) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return kv.rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Delete removes keys one at a time so it also works when they live in
+// different cluster slots.
+func (kv *RedisKV) Delete(ctx context.Context, keys ...string) error {
+	pipe := kv.rdb.Pipeline()
+	for _, k := range keys {
+		pipe.Del(ctx, k)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (kv *RedisKV) DeleteIf(ctx context.Context, key string, value []byte) error {
+	return deleteIfScript.Run(ctx, kv.rdb, []string{key}, value).Err()
+}
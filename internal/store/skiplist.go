@@ -0,0 +1,179 @@
+package store
+
+import "math/rand/v2"
+
+const (
+	skipMaxLevel = 32
+	skipP        = 0.25
+)
+
+// skiplist is an order-statistic skip list modelled on Redis' zskiplist.
+// Nodes are ordered by score descending, then player descending, so the
+// position of a node is its zero-based leaderboard rank. Each forward link
+// records how many nodes it skips, which lets ranks be found in O(log n).
+type skiplist struct {
+	head   *skipNode
+	level  int
+	length int64
+}
+
+type skipNode struct {
+	player string
+	score  float64
+	levels []skipLevel
+}
+
+type skipLevel struct {
+	forward *skipNode
+	span    int64
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  &skipNode{levels: make([]skipLevel, skipMaxLevel)},
+		level: 1,
+	}
+}
+
+// ahead reports whether n sorts before (score, player).
+func (n *skipNode) ahead(score float64, player string) bool {
+	return n.score > score || (n.score == score && n.player > player)
+}
+
+// behind reports whether n sorts after (score, player).
+func (n *skipNode) behind(score float64, player string) bool {
+	return n.score < score || (n.score == score && n.player < player)
+}
+
+func (n *skipNode) next() *skipNode {
+	return n.levels[0].forward
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipMaxLevel && rand.Float64() < skipP {
+		level++
+	}
+	return level
+}
+
+// insert adds a node; the caller ensures (score, player) is not present.
+func (sl *skiplist) insert(score float64, player string) {
+	var update [skipMaxLevel]*skipNode
+	var rank [skipMaxLevel]int64
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && x.levels[i].forward.ahead(score, player) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.head
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	n := &skipNode{player: player, score: score, levels: make([]skipLevel, level)}
+	for i := 0; i < level; i++ {
+		n.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = n
+		n.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+	sl.length++
+}
+
+// remove deletes (score, player) and reports whether it was present.
+func (sl *skiplist) remove(score float64, player string) bool {
+	var update [skipMaxLevel]*skipNode
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.ahead(score, player) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.next()
+	if x == nil || x.score != score || x.player != player {
+		return false
+	}
+	for i := 0; i < sl.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for sl.level > 1 && sl.head.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+	return true
+}
+
+// rank returns the zero-based position of (score, player), or -1.
+func (sl *skiplist) rank(score float64, player string) int64 {
+	var traversed int64
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !x.levels[i].forward.behind(score, player) {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x != sl.head && x.score == score && x.player == player {
+			return traversed - 1
+		}
+	}
+	return -1
+}
+
+// at returns the node at zero-based position pos, or nil.
+func (sl *skiplist) at(pos int64) *skipNode {
+	if pos < 0 || pos >= sl.length {
+		return nil
+	}
+	var traversed int64
+	target := pos + 1
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= target {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == target {
+			return x
+		}
+	}
+	return nil
+}
+
+// countWhile returns the length of the longest prefix of nodes whose scores
+// satisfy keep. keep must hold for a prefix of the list and fail after it.
+func (sl *skiplist) countWhile(keep func(score float64) bool) int64 {
+	var n int64
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && keep(x.levels[i].forward.score) {
+			n += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	return n
+}
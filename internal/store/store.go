@@ -0,0 +1,152 @@
+// Package store defines the storage the handlers and middleware depend on,
+// with Redis and in-memory implementations.
+package store
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrNotFound is returned for players or keys that do not exist.
+var ErrNotFound = errors.New("store: not found")
+
+// Member is a player and score on a leaderboard.
+type Member struct {
+	Player string
+	Score  float64
+}
+
+// Bound is one end of a score range.
+type Bound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// ScoreRange selects scores between Min and Max. Use ±Inf for open ends.
+type ScoreRange struct {
+	Min, Max Bound
+}
+
+// All matches every score.
+var All = ScoreRange{Min: Bound{Value: math.Inf(-1)}, Max: Bound{Value: math.Inf(1)}}
+
+// Above matches scores strictly greater than v.
+func Above(v float64) ScoreRange {
+	return ScoreRange{Min: Bound{Value: v, Exclusive: true}, Max: Bound{Value: math.Inf(1)}}
+}
+
+// Below matches scores strictly less than v.
+func Below(v float64) ScoreRange {
+	return ScoreRange{Min: Bound{Value: math.Inf(-1)}, Max: Bound{Value: v, Exclusive: true}}
+}
+
+// Between matches scores in [lo, hi].
+func Between(lo, hi float64) ScoreRange {
+	return ScoreRange{Min: Bound{Value: lo}, Max: Bound{Value: hi}}
+}
+
+// Contains reports whether score lies within r.
+func (r ScoreRange) Contains(score float64) bool {
+	if score < r.Min.Value || (r.Min.Exclusive && score == r.Min.Value) {
+		return false
+	}
+	if score > r.Max.Value || (r.Max.Exclusive && score == r.Max.Value) {
+		return false
+	}
+	return true
+}
+
+// Version identifies the state of a board or of a player's score.
+type Version struct {
+	// Count advances on every write that changes it.
+	Count int64
+	// Modified is the time of the last such write, zero if unknown.
+	Modified time.Time
+}
+
+// Update describes a player's standing before and after a write. Below
+// counts players with a strictly lower score; Total is the board size.
+type Update struct {
+	Score    float64
+	OldScore float64
+	// Existed reports whether the player was on the board before the write.
+	Existed bool
+	// Changed is false when the write left the board untouched, e.g. a
+	// SetIfBetter with a lower score or a Remove of an absent player.
+	Changed  bool
+	OldBelow int64
+	NewBelow int64
+	OldTotal int64
+	NewTotal int64
+}
+
+// LeaderboardStore holds one leaderboard. Ordering is by score descending,
+// ties by player descending, and positions are zero-based.
+type LeaderboardStore interface {
+	// Board returns the leaderboard's name.
+	Board() string
+
+	// Increment adds delta to the player's score, creating the player at
+	// delta when absent.
+	Increment(ctx context.Context, player string, delta float64) (Update, error)
+	// SetIfBetter stores score only when the player is absent or currently
+	// scores lower.
+	SetIfBetter(ctx context.Context, player string, score float64) (Update, error)
+	// Remove deletes the player; Update.Changed reports whether it existed.
+	Remove(ctx context.Context, player string) (Update, error)
+
+	// Score returns ErrNotFound for absent players.
+	Score(ctx context.Context, player string) (float64, error)
+	// Rank returns the player's position, or ErrNotFound.
+	Rank(ctx context.Context, player string) (int64, error)
+	// Card returns the number of players.
+	Card(ctx context.Context) (int64, error)
+	// Count returns the number of players scoring within r.
+	Count(ctx context.Context, r ScoreRange) (int64, error)
+	// DistinctAbove returns the number of distinct scores greater than score.
+	DistinctAbove(ctx context.Context, score float64) (int64, error)
+
+	// RangeByRank returns positions start through stop inclusive. Negative
+	// positions count from the end, -1 being the last player.
+	RangeByRank(ctx context.Context, start, stop int64) ([]Member, error)
+	// RangeByScore returns up to count players within r after skipping
+	// offset of them. A negative count returns all remaining players.
+	RangeByScore(ctx context.Context, r ScoreRange, offset, count int64) ([]Member, error)
+	// Sample returns up to n distinct players chosen at random.
+	Sample(ctx context.Context, n int) ([]Member, error)
+	// Sum returns the total of all scores. exact is false when the store
+	// cannot vouch for it, e.g. for data written before totals were kept.
+	Sum(ctx context.Context) (sum float64, exact bool, err error)
+
+	// Version counts the writes that changed the board or, when player is
+	// not "", gives the board's version as of that player's last write, so
+	// a player removed and added again never repeats an earlier version.
+	// Players not on the board have the zero Version. Anything read from
+	// the board stays current until the version moves on.
+	Version(ctx context.Context, player string) (Version, error)
+}
+
+// Watcher is implemented by stores that announce changing writes, made by
+// this process or any other.
+type Watcher interface {
+	// Watch calls fn with the player of each write that changes the board
+	// until ctx is done. An empty player means changes may have been
+	// missed. fn must not block.
+	Watch(ctx context.Context, fn func(player string))
+}
+
+// KV is a byte store with expiry, used for idempotency markers and cached
+// responses.
+type KV interface {
+	// Get returns ErrNotFound for absent or expired keys.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX stores value only when key is absent and reports whether it did.
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	// DeleteIf removes key only while it still holds value, so a lock
+	// that expired and was taken by someone else is left alone.
+	DeleteIf(ctx context.Context, key string, value []byte) error
+}
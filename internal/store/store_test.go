@@ -0,0 +1,329 @@
+package store_test
+
+import (
+	"context"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"go-redis/internal/keys"
+	"go-redis/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func() store.LeaderboardStore {
+		return store.NewMemoryStore("test")
+	})
+}
+
+// TestRedisStore needs a Redis server at REDIS_ADDR. Each case gets a
+// board of its own, deleted when the case ends.
+func TestRedisStore(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("Redis unavailable: %v", err)
+	}
+
+	n := 0
+	testStore(t, func() store.LeaderboardStore {
+		n++
+		board := "test:" + strconv.FormatInt(time.Now().UnixNano(), 36) + ":" + strconv.Itoa(n)
+		t.Cleanup(func() {
+			rdb.Del(context.Background(), keys.Board(board), keys.Stats(board), keys.Distinct(board),
+				keys.DistinctCounts(board), keys.Version(board))
+		})
+		return store.NewRedisStore(rdb, board)
+	})
+}
+
+// testStore checks that a LeaderboardStore behaves as the interface
+// documents. newStore must return an empty board each time it is called.
+func testStore(t *testing.T, newStore func() store.LeaderboardStore) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, s store.LeaderboardStore)
+	}{
+		{"increment adds a player", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 1)
+			checkUpdate(t, "Increment", mustIncrement(t, s, "b", 5), store.Update{
+				Score: 5, Changed: true,
+				OldBelow: 0, NewBelow: 1, OldTotal: 1, NewTotal: 2,
+			})
+		}},
+		{"increment moves a player", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 1)
+			mustIncrement(t, s, "b", 3)
+			checkUpdate(t, "Increment", mustIncrement(t, s, "a", 5), store.Update{
+				Score: 6, OldScore: 1, Existed: true, Changed: true,
+				OldBelow: 0, NewBelow: 1, OldTotal: 2, NewTotal: 2,
+			})
+		}},
+		{"set if better raises a score", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 4)
+			mustIncrement(t, s, "b", 2)
+			u, err := s.SetIfBetter(ctx, "b", 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkUpdate(t, "SetIfBetter", u, store.Update{
+				Score: 7, OldScore: 2, Existed: true, Changed: true,
+				OldBelow: 0, NewBelow: 1, OldTotal: 2, NewTotal: 2,
+			})
+		}},
+		{"set if better keeps a higher score", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 4)
+			mustIncrement(t, s, "b", 2)
+			for _, score := range []float64{4, 3} {
+				u, err := s.SetIfBetter(ctx, "a", score)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkUpdate(t, "SetIfBetter", u, store.Update{
+					Score: 4, OldScore: 4, Existed: true,
+					OldBelow: 1, NewBelow: 1, OldTotal: 2, NewTotal: 2,
+				})
+			}
+		}},
+		{"remove deletes a player", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 1)
+			mustIncrement(t, s, "b", 3)
+			u, err := s.Remove(ctx, "b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkUpdate(t, "Remove", u, store.Update{
+				OldScore: 3, Existed: true, Changed: true,
+				OldBelow: 1, OldTotal: 2, NewTotal: 1,
+			})
+			if _, err := s.Score(ctx, "b"); err != store.ErrNotFound {
+				t.Errorf("Score after Remove: got err %v, want ErrNotFound", err)
+			}
+		}},
+		{"remove ignores an absent player", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			mustIncrement(t, s, "a", 1)
+			u, err := s.Remove(ctx, "b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkUpdate(t, "Remove", u, store.Update{OldTotal: 1, NewTotal: 1})
+		}},
+		{"ties order by player descending", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			for _, p := range []string{"b", "d", "a", "c"} {
+				mustIncrement(t, s, p, 10)
+			}
+			mustIncrement(t, s, "e", 20)
+			want := []store.Member{{"e", 20}, {"d", 10}, {"c", 10}, {"b", 10}, {"a", 10}}
+			got, err := s.RangeByRank(ctx, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMembers(t, "RangeByRank(0, -1)", got, want)
+			got, err = s.RangeByRank(ctx, 2, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMembers(t, "RangeByRank(2, 3)", got, want[2:4])
+			got, err = s.RangeByRank(ctx, -2, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMembers(t, "RangeByRank(-2, -1)", got, want[3:])
+			for i, m := range want {
+				rank, err := s.Rank(ctx, m.Player)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if rank != int64(i) {
+					t.Errorf("Rank(%q) = %d, want %d", m.Player, rank, i)
+				}
+			}
+			if _, err := s.Rank(ctx, "z"); err != store.ErrNotFound {
+				t.Errorf("Rank of an absent player: got err %v, want ErrNotFound", err)
+			}
+		}},
+		{"score ranges honour exclusive bounds", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			for i, p := range []string{"a", "b", "c", "d"} {
+				mustIncrement(t, s, p, float64(i+1))
+			}
+			ranges := []struct {
+				name string
+				r    store.ScoreRange
+				want []store.Member
+			}{
+				{"All", store.All, []store.Member{{"d", 4}, {"c", 3}, {"b", 2}, {"a", 1}}},
+				{"Above(2)", store.Above(2), []store.Member{{"d", 4}, {"c", 3}}},
+				{"Below(3)", store.Below(3), []store.Member{{"b", 2}, {"a", 1}}},
+				{"Between(2, 3)", store.Between(2, 3), []store.Member{{"c", 3}, {"b", 2}}},
+				{"(1, 4)", store.ScoreRange{
+					Min: store.Bound{Value: 1, Exclusive: true},
+					Max: store.Bound{Value: 4, Exclusive: true},
+				}, []store.Member{{"c", 3}, {"b", 2}}},
+				{"(3, 3]", store.ScoreRange{
+					Min: store.Bound{Value: 3, Exclusive: true},
+					Max: store.Bound{Value: 3},
+				}, []store.Member{}},
+			}
+			for _, tt := range ranges {
+				n, err := s.Count(ctx, tt.r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != int64(len(tt.want)) {
+					t.Errorf("Count(%s) = %d, want %d", tt.name, n, len(tt.want))
+				}
+				got, err := s.RangeByScore(ctx, tt.r, 0, -1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkMembers(t, "RangeByScore("+tt.name+")", got, tt.want)
+			}
+			got, err := s.RangeByScore(ctx, store.All, 1, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkMembers(t, "RangeByScore(All, 1, 2)", got, []store.Member{{"c", 3}, {"b", 2}})
+		}},
+		{"distinct above counts each score once", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			for p, score := range map[string]float64{"a": 5, "b": 5, "c": 3, "d": 1} {
+				mustIncrement(t, s, p, score)
+			}
+			checkDistinct(t, s, map[float64]int64{0: 3, 1: 2, 3: 1, 4: 1, 5: 0})
+			if _, err := s.Remove(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+			checkDistinct(t, s, map[float64]int64{0: 3, 3: 1})
+			mustIncrement(t, s, "b", -4)
+			checkDistinct(t, s, map[float64]int64{0: 2, 1: 1, 3: 0})
+		}},
+		{"sum follows every write", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			checkSum(t, s, 0)
+			mustIncrement(t, s, "a", 1.5)
+			mustIncrement(t, s, "b", 2.5)
+			checkSum(t, s, 4)
+			mustIncrement(t, s, "a", 3)
+			if _, err := s.SetIfBetter(ctx, "b", 10); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.SetIfBetter(ctx, "a", 1); err != nil {
+				t.Fatal(err)
+			}
+			checkSum(t, s, 14.5)
+			if _, err := s.Remove(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+			checkSum(t, s, 10)
+		}},
+		{"version advances on changing writes", func(t *testing.T, ctx context.Context, s store.LeaderboardStore) {
+			if v := mustVersion(t, s, ""); v != (store.Version{}) {
+				t.Errorf("Version of an empty board = %+v, want zero", v)
+			}
+			mustIncrement(t, s, "a", 1)
+			mustIncrement(t, s, "b", 1)
+			board := mustVersion(t, s, "")
+			if board.Count != 2 || board.Modified.IsZero() {
+				t.Errorf("Version after two writes = %+v, want count 2 and a time", board)
+			}
+			if v := mustVersion(t, s, "a"); v.Count != 1 {
+				t.Errorf("Version(a) = %d, want the board's count at its write, 1", v.Count)
+			}
+
+			if _, err := s.SetIfBetter(ctx, "a", 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Remove(ctx, "z"); err != nil {
+				t.Fatal(err)
+			}
+			if v := mustVersion(t, s, ""); v.Count != 2 {
+				t.Errorf("Version after unchanging writes = %d, want 2", v.Count)
+			}
+
+			if _, err := s.Remove(ctx, "a"); err != nil {
+				t.Fatal(err)
+			}
+			if v := mustVersion(t, s, "a"); v != (store.Version{}) {
+				t.Errorf("Version of a removed player = %+v, want zero", v)
+			}
+			mustIncrement(t, s, "a", 1)
+			if v := mustVersion(t, s, "a"); v.Count != 4 {
+				t.Errorf("Version of a re-added player = %d, want 4", v.Count)
+			}
+			if v := mustVersion(t, s, "b"); v.Count != 2 {
+				t.Errorf("Version(b) = %d, want 2: other players' writes must not move it", v.Count)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, context.Background(), newStore())
+		})
+	}
+}
+
+func mustIncrement(t *testing.T, s store.LeaderboardStore, player string, delta float64) store.Update {
+	t.Helper()
+	u, err := s.Increment(context.Background(), player, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func mustVersion(t *testing.T, s store.LeaderboardStore, player string) store.Version {
+	t.Helper()
+	v, err := s.Version(context.Background(), player)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func checkUpdate(t *testing.T, op string, got, want store.Update) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s = %+v, want %+v", op, got, want)
+	}
+}
+
+func checkMembers(t *testing.T, what string, got, want []store.Member) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func checkDistinct(t *testing.T, s store.LeaderboardStore, want map[float64]int64) {
+	t.Helper()
+	for score, n := range want {
+		got, err := s.DistinctAbove(context.Background(), score)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != n {
+			t.Errorf("DistinctAbove(%g) = %d, want %d", score, got, n)
+		}
+	}
+}
+
+func checkSum(t *testing.T, s store.LeaderboardStore, want float64) {
+	t.Helper()
+	sum, exact, err := s.Sum(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exact || math.Abs(sum-want) > 1e-9 {
+		t.Errorf("Sum = %g (exact %t), want %g (exact)", sum, exact, want)
+	}
+}
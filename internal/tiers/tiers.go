@@ -0,0 +1,141 @@
+// Package tiers assigns players to named divisions by score thresholds or
+// percentile bands.
+package tiers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Mode string
+
+const (
+	// ByScore places a player by absolute score.
+	ByScore Mode = "score"
+	// ByPercentile places a player by percentile (0-100, higher is better).
+	ByPercentile Mode = "percentile"
+)
+
+// Tier is a division whose lower bound, a score or a percentile depending on
+// the definition's mode, is Min. Its upper bound is the next tier's Min.
+type Tier struct {
+	Name string  `json:"name"`
+	Min  float64 `json:"min"`
+}
+
+// Definition is the ordered set of tiers of one leaderboard.
+type Definition struct {
+	Mode  Mode
+	Tiers []Tier // ascending by Min
+}
+
+// Classify returns the tier for a player with the given score and
+// percentile, or "" when d is nil or the player is below the lowest tier.
+func (d *Definition) Classify(score, percentile float64) string {
+	if d == nil {
+		return ""
+	}
+	v := score
+	if d.Mode == ByPercentile {
+		v = percentile
+	}
+	name := ""
+	for _, t := range d.Tiers {
+		if v < t.Min {
+			break
+		}
+		name = t.Name
+	}
+	return name
+}
+
+// Bounds returns the half-open interval [lo, hi) of the named tier; hi is
+// +Inf for the top tier.
+func (d *Definition) Bounds(name string) (lo, hi float64, ok bool) {
+	if d == nil {
+		return 0, 0, false
+	}
+	for i, t := range d.Tiers {
+		if t.Name != name {
+			continue
+		}
+		hi = math.Inf(1)
+		if i+1 < len(d.Tiers) {
+			hi = d.Tiers[i+1].Min
+		}
+		return t.Min, hi, true
+	}
+	return 0, 0, false
+}
+
+// Set holds tier definitions keyed by leaderboard.
+type Set map[string]*Definition
+
+// For returns the definition of board, or nil when it has none.
+func (s Set) For(board string) *Definition {
+	return s[board]
+}
+
+// Parse reads a definition such as "score:Bronze=0,Silver=1000,Gold=5000".
+func Parse(spec string) (*Definition, error) {
+	mode, list, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("tiers: %q: missing mode", spec)
+	}
+	d := &Definition{Mode: Mode(strings.TrimSpace(mode))}
+	if d.Mode != ByScore && d.Mode != ByPercentile {
+		return nil, fmt.Errorf("tiers: unknown mode %q", mode)
+	}
+
+	seen := map[string]bool{}
+	for _, item := range strings.Split(list, ",") {
+		name, bound, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("tiers: %q: want name=min", item)
+		}
+		v, err := strconv.ParseFloat(bound, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("tiers: %q: invalid bound", item)
+		}
+		if d.Mode == ByPercentile && (v < 0 || v > 100) {
+			return nil, fmt.Errorf("tiers: %q: percentile must be within 0-100", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tiers: duplicate tier %q", name)
+		}
+		seen[name] = true
+		d.Tiers = append(d.Tiers, Tier{Name: name, Min: v})
+	}
+	sort.SliceStable(d.Tiers, func(i, j int) bool { return d.Tiers[i].Min < d.Tiers[j].Min })
+	for i := 1; i < len(d.Tiers); i++ {
+		if d.Tiers[i].Min == d.Tiers[i-1].Min {
+			return nil, fmt.Errorf("tiers: %s and %s share a bound", d.Tiers[i-1].Name, d.Tiers[i].Name)
+		}
+	}
+	return d, nil
+}
+
+// ParseSet reads per-leaderboard definitions separated by ";", e.g.
+// "scores=score:Bronze=0,Silver=1000;weekly=percentile:Bronze=0,Gold=90".
+func ParseSet(spec string) (Set, error) {
+	set := Set{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		board, def, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("tiers: %q: want board=definition", entry)
+		}
+		d, err := Parse(def)
+		if err != nil {
+			return nil, err
+		}
+		set[strings.TrimSpace(board)] = d
+	}
+	return set, nil
+}
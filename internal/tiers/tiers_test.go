@@ -0,0 +1,115 @@
+package tiers
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want *Definition
+	}{
+		{"score:Bronze=0,Silver=1000,Gold=5000", &Definition{Mode: ByScore, Tiers: []Tier{{"Bronze", 0}, {"Silver", 1000}, {"Gold", 5000}}}},
+		{" percentile : Gold=90, Bronze=0 ,Silver=50", &Definition{Mode: ByPercentile, Tiers: []Tier{{"Bronze", 0}, {"Silver", 50}, {"Gold", 90}}}},
+		{"score:Debt=-100.5,Even=0", &Definition{Mode: ByScore, Tiers: []Tier{{"Debt", -100.5}, {"Even", 0}}}},
+		{"percentile:All=100", &Definition{Mode: ByPercentile, Tiers: []Tier{{"All", 100}}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec, want string
+	}{
+		{"Bronze=0", "missing mode"},
+		{"rank:Bronze=0", "unknown mode"},
+		{"score:", "want name=min"},
+		{"score:Bronze", "want name=min"},
+		{"score:=0", "want name=min"},
+		{"score:Bronze=low", "invalid bound"},
+		{"score:Bronze=NaN", "invalid bound"},
+		{"score:Bronze=Inf", "invalid bound"},
+		{"percentile:Bronze=-1", "percentile must be within 0-100"},
+		{"percentile:Bronze=100.5", "percentile must be within 0-100"},
+		{"score:Bronze=0,Bronze=10", "duplicate tier"},
+		{"score:Bronze=0,Silver=0", "share a bound"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	set, err := ParseSet(" scores=score:Bronze=0,Gold=10 ; ;weekly=percentile:Top=90")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 || set.For("scores").Mode != ByScore || set.For("weekly").Mode != ByPercentile || set.For("daily") != nil {
+		t.Errorf("ParseSet = %+v", set)
+	}
+	if set, err := ParseSet(""); err != nil || len(set) != 0 {
+		t.Errorf("ParseSet(\"\") = %v, %v", set, err)
+	}
+	for _, spec := range []string{"score:Bronze=0", "scores=rank:Bronze=0"} {
+		if _, err := ParseSet(spec); err == nil {
+			t.Errorf("ParseSet(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestClassifyAndBounds(t *testing.T) {
+	score, _ := Parse("score:Bronze=0,Silver=1000,Gold=5000")
+	pct, _ := Parse("percentile:Bronze=0,Silver=50,Gold=90")
+	tests := []struct {
+		d                 *Definition
+		score, percentile float64
+		want              string
+	}{
+		{score, -1, 100, ""},
+		{score, 0, 0, "Bronze"},
+		{score, 999.9, 100, "Bronze"},
+		{score, 1000, 0, "Silver"},
+		{score, 1e9, 0, "Gold"},
+		{pct, 1e9, 49.9, "Bronze"},
+		{pct, 0, 50, "Silver"},
+		{pct, 0, 100, "Gold"},
+		{nil, 1e9, 100, ""},
+	}
+	for _, tt := range tests {
+		got := tt.d.Classify(tt.score, tt.percentile)
+		if got != tt.want {
+			t.Errorf("Classify(%v, %v) = %q, want %q", tt.score, tt.percentile, got, tt.want)
+		}
+		if got == "" {
+			continue
+		}
+		v := tt.score
+		if tt.d.Mode == ByPercentile {
+			v = tt.percentile
+		}
+		if lo, hi, ok := tt.d.Bounds(got); !ok || v < lo || v >= hi {
+			t.Errorf("Bounds(%s) = [%v, %v), %t; %v not within", got, lo, hi, ok, v)
+		}
+	}
+	if _, hi, _ := score.Bounds("Gold"); !math.IsInf(hi, 1) {
+		t.Errorf("top tier's upper bound = %v", hi)
+	}
+	if _, _, ok := score.Bounds("Platinum"); ok {
+		t.Error("Bounds of an unknown tier succeeded")
+	}
+}